@@ -0,0 +1,55 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+)
+
+// DecodeBase64JSON returns FieldFunc that sanitizes json documents encoded
+// as base64 values, like payloads of JWT-style tokens. If value decodes as
+// base64 to a valid json object or array, document is sanitized with fn the
+// same way Message does, and the value is substituted with the result
+// encoded back in the same base64 flavor. Standard and URL-safe alphabets,
+// both padded and unpadded, are recognized.
+//
+// Values that are not base64, or do not decode to json object or array, are
+// left untouched, as are documents fn did not change. To also apply fn to
+// plain values, combine functions with Chain:
+//
+//	Chain(DecodeBase64JSON(fn), fn)
+func DecodeBase64JSON(fn FieldFunc) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if len(value) < 4 || fn == nil {
+			return "", false
+		}
+		for _, enc := range base64Encodings {
+			doc, err := enc.DecodeString(value)
+			if err != nil {
+				continue
+			}
+			doc = bytes.TrimSpace(doc)
+			if len(doc) == 0 || doc[0] != '{' && doc[0] != '[' || !json.Valid(doc) {
+				return "", false
+			}
+			var masked bool
+			out, err := Message(nil, doc, func(key, value string) (string, bool) {
+				val, ok := fn(key, value)
+				masked = masked || ok
+				return val, ok
+			})
+			if err != nil || !masked {
+				return "", false
+			}
+			return enc.EncodeToString(out), true
+		}
+		return "", false
+	}
+}
+
+var base64Encodings = []*base64.Encoding{
+	base64.StdEncoding,
+	base64.URLEncoding,
+	base64.RawStdEncoding,
+	base64.RawURLEncoding,
+}
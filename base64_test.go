@@ -0,0 +1,28 @@
+package sanitize_test
+
+import (
+	"encoding/base64"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestDecodeBase64JSON(t *testing.T) {
+	payload := `{"sub":"42", "Msg":"secret <b>", "nested":{"a":"x"}}`
+	sanitized := `{"sub":"42","Msg":"********","nested":{"a":"********"}}`
+	std := base64.StdEncoding.EncodeToString([]byte(payload))
+	raw := base64.RawURLEncoding.EncodeToString([]byte(payload + " "))
+	keep := base64.StdEncoding.EncodeToString([]byte(`{"sub":"42","x":"<y>"}`))
+	input := `{"token":"` + std + `","jwt":"` + raw + `","keep":"` + keep + `",` +
+		`"word":"test","notjson":"aGVsbG8gd29ybGQ=","scalar":"MTIzNA==","Msg":"c2VjcmV0"}`
+	want := `{"token":"` + base64.StdEncoding.EncodeToString([]byte(sanitized)) +
+		`","jwt":"` + base64.RawURLEncoding.EncodeToString([]byte(sanitized)) + `","keep":"` + keep + `",` +
+		`"word":"test","notjson":"aGVsbG8gd29ybGQ=","scalar":"MTIzNA==","Msg":"********"}`
+	dst, err := sanitize.Message(nil, []byte(input), sanitize.Chain(sanitize.DecodeBase64JSON(fn), fn))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,169 @@
+package sanitize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// bom is UTF-8 encoded byte order mark. Some producers prepend it to json
+// payloads; it is never a part of json itself, so it is skipped on input and
+// not written to the output.
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// setInput makes s decode payload from src, skipping leading byte order mark
+func (s *state) setInput(src []byte) {
+	if bytes.HasPrefix(src, bom) {
+		src = src[len(bom):]
+		s.base = int64(len(bom))
+	}
+	if s.opts.Relaxed {
+		var x relaxer
+		src = x.flush(x.write(make([]byte, 0, len(src)), src))
+	}
+	s.badUTF8 = -1
+	if s.opts.Strict {
+		s.badUTF8 = invalidUTF8(src)
+	}
+	s.src = src
+	s.sc.reset(src)
+	s.tok = &s.sc
+}
+
+// setReader makes s decode payload read from r, skipping leading byte order
+// mark
+func (s *state) setReader(r io.Reader) {
+	s.badUTF8 = -1
+	if s.opts.BufSize > 0 {
+		r = bufio.NewReaderSize(r, s.opts.BufSize)
+	}
+	if s.opts.Strict {
+		r = &utf8Reader{r: r}
+	}
+	if s.opts.Relaxed {
+		r = &relaxReader{r: r}
+	}
+	s.bom = bomReader{r: r, s: s}
+	s.jd.dec = json.NewDecoder(&s.bom)
+	s.jd.dec.UseNumber()
+	s.tok = &s.jd
+}
+
+// bomReader reads from r skipping leading byte order mark. It also enforces
+// Options.MaxTokenSize, limiting read ahead of the decoder.
+type bomReader struct {
+	r       io.Reader
+	s       *state // its base is set once byte order mark is skipped
+	buf     [3]byte
+	i, n    int // unread part of buf read while looking for the mark
+	checked bool
+	read    int64 // number of bytes returned to the decoder
+
+	// whitespace between tokens returned to the decoder, which does not
+	// count towards Options.MaxTokenSize; only tracked if it is set
+	blanks   []blankRun
+	str, esc bool // whether returned input ends within a string, or after \ in it
+}
+
+// blankRun is a span of input offsets holding whitespace between tokens
+type blankRun struct{ start, end int64 }
+
+// tokenSlack is how much input past Options.MaxTokenSize may be read ahead of
+// the last complete token, not counting whitespace between tokens
+const tokenSlack = 4096
+
+func (b *bomReader) Read(p []byte) (int, error) {
+	max := b.s.opts.MaxTokenSize
+	if max > 0 {
+		limit := int64(max) + tokenSlack - b.readAhead(b.s.jd.dec.InputOffset())
+		if limit <= 0 {
+			return 0, fmt.Errorf("%w: %d", ErrTokenTooLarge, max)
+		}
+		if int64(len(p)) > limit {
+			p = p[:limit]
+		}
+	}
+	n, err := b.readBOM(p)
+	if max > 0 {
+		b.track(p[:n])
+	}
+	b.read += int64(n)
+	return n, err
+}
+
+// readAhead returns the number of bytes returned to the decoder past offset
+// off, not counting whitespace between tokens
+func (b *bomReader) readAhead(off int64) int64 {
+	for len(b.blanks) > 0 && b.blanks[0].end <= off {
+		b.blanks = b.blanks[1:]
+	}
+	n := b.read - off
+	for _, r := range b.blanks {
+		if r.start < off {
+			r.start = off
+		}
+		n -= r.end - r.start
+	}
+	return n
+}
+
+// track records whitespace between tokens in p, which is about to be
+// returned to the decoder
+func (b *bomReader) track(p []byte) {
+	for i, c := range p {
+		switch {
+		case b.esc:
+			b.esc = false
+		case b.str && c == '\\':
+			b.esc = true
+		case c == '"':
+			b.str = !b.str
+		case !b.str && (c == ' ' || c == '\t' || c == '\n' || c == '\r'):
+			off := b.read + int64(i)
+			if k := len(b.blanks) - 1; k >= 0 && b.blanks[k].end == off {
+				b.blanks[k].end++
+			} else {
+				b.blanks = append(b.blanks, blankRun{start: off, end: off + 1})
+			}
+		}
+	}
+}
+
+func (b *bomReader) readBOM(p []byte) (int, error) {
+	if !b.checked {
+		// only read ahead while input matches the mark, so that reads
+		// never block on data that is not needed to decode the payload
+		for b.n < len(bom) {
+			k, err := b.r.Read(b.buf[b.n : b.n+1])
+			b.n += k
+			if k > 0 && b.buf[b.n-1] != bom[b.n-1] {
+				break
+			}
+			if err != nil {
+				if err != io.EOF || b.n == 0 {
+					return 0, err
+				}
+				break
+			}
+		}
+		b.checked = true
+		if bytes.Equal(b.buf[:b.n], bom) {
+			b.n = 0
+			b.s.base = int64(len(bom))
+		}
+	}
+	if b.i < b.n {
+		// fill the rest of p from r, so that multi-byte characters
+		// are not split between reads
+		k := copy(p, b.buf[b.i:b.n])
+		b.i += k
+		if b.i < b.n || k == len(p) {
+			return k, nil
+		}
+		n, err := b.r.Read(p[k:])
+		return k + n, err
+	}
+	return b.r.Read(p)
+}
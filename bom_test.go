@@ -0,0 +1,85 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestBOM(t *testing.T) {
+	const bom = "\xEF\xBB\xBF"
+	for _, in := range []string{bom + input, bom + " \r\n\t" + input} {
+		dst, err := sanitize.Message(nil, []byte(in), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != want {
+			t.Fatalf("Message got:\n%q\nwant:\n%q", got, want)
+		}
+		buf := new(bytes.Buffer)
+		if err := sanitize.Stream(buf, iotest.OneByteReader(strings.NewReader(in)), fn); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Fatalf("Stream got:\n%q\nwant:\n%q", got, want)
+		}
+	}
+	dst, err := sanitize.MessagePreserve(nil, []byte(bom+`{"a": "x"}`), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), `{"a": "********"}`; got != want {
+		t.Fatalf("MessagePreserve got %q, want %q", got, want)
+	}
+	matches, err := sanitize.Report([]byte(bom+`{"a": "x"}`), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(matches) != 1 || matches[0].Offset != 9 {
+		t.Fatalf("unexpected report: %+v", matches)
+	}
+}
+
+func TestBOMInvalid(t *testing.T) {
+	for _, in := range []string{"\xEF\xBB" + input, "\xEF" + input, "\xEF\xBB\xBF\xEF\xBB\xBF" + input} {
+		var serr *sanitize.SyntaxError
+		buf := new(bytes.Buffer)
+		if err := sanitize.Stream(buf, strings.NewReader(in), fn); !errors.As(err, &serr) {
+			t.Errorf("Stream %q: unexpected error: %v", in[:8], err)
+		}
+		if _, err := sanitize.Message(nil, []byte(in), fn); !errors.As(err, &serr) {
+			t.Errorf("Message %q: unexpected error: %v", in[:8], err)
+		}
+	}
+	// partial mark followed by bytes that only look like a payload once the
+	// mismatching byte is dropped
+	for _, in := range []string{"\xEF\xBB[1", "\xEF\xBBX\"secret\"", "\xEF\xBB{\"a\":1}", "\xEFX1", "\xEF\xBB"} {
+		var calls1, calls2 int
+		count := func(n *int) sanitize.FieldFunc {
+			return func(key, value string) (string, bool) { *n++; return fn(key, value) }
+		}
+		dst, err1 := sanitize.Message(nil, []byte(in), count(&calls1))
+		buf := new(bytes.Buffer)
+		err2 := sanitize.Stream(buf, iotest.OneByteReader(strings.NewReader(in)), count(&calls2))
+		if err1 == nil || err2 == nil || err1.Error() != err2.Error() {
+			t.Errorf("%q: Message error: %v, Stream error: %v", in, err1, err2)
+		}
+		if calls1 != calls2 || dst != nil {
+			t.Errorf("%q: fn called %d times by Message, %d times by Stream", in, calls1, calls2)
+		}
+	}
+	// input shorter than byte order mark
+	for _, in := range []string{"1", "[]", "\xEF\xBB\xBF"} {
+		buf := new(bytes.Buffer)
+		if err := sanitize.Stream(buf, strings.NewReader(in), fn); err != nil {
+			t.Errorf("%q: %v", in, err)
+		}
+		if got, want := buf.String(), strings.TrimPrefix(in, "\xEF\xBB\xBF"); got != want {
+			t.Errorf("%q: got %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,5 +1,6 @@
 // Command json-sanitize sanitizes string fields of json input replacing them
-// with "REDACTED" value.
+// with "********" value, the same default placeholder sanitize.Mask the
+// library uses.
 //
 // Command takes list of case-sensitive field names as its arguments, then reads
 // arbitrary json structure over stdin and writes sanitized version to stdout.
@@ -10,38 +11,237 @@
 //
 // will produce this:
 //
-// 	{"foo":"REDACTED","bar":"bar"}
+// 	{"foo":"********","bar":"bar"}
+//
+// Field names can also be read from a file given with -fields-file flag, one
+// name per line; blank lines and lines starting with # are ignored. Names from
+// the file are merged with names given as arguments.
+//
+// Use -mask flag to use another replacement value instead of "********".
+// With -all flag every string field is sanitized and no field names are
+// expected.
+// Field names are matched case-insensitively if -i (-ignore-case) flag is set.
+// With -regex flag arguments are treated as regular expressions in Go syntax
+// (https://golang.org/s/re2syntax), and field is sanitized if its name matches
+// any of them. Patterns are not anchored, so use ^ and $ to match the whole
+// name.
+//
+// With -by-value flag arguments are matched against string values instead of
+// field names: field is sanitized if its value equals any of arguments, or
+// matches any of them with -regex flag, regardless of its name. Names read
+// from -fields-file are treated as values then, and -i flag makes values
+// match case-insensitively.
+//
+// With -map flag field names and their replacements are read from a json
+// file holding an object like {"password":"***","ssn":"XXX-XX-XXXX"}: each
+// field listed there is sanitized with its own replacement value. Names in
+// this file are matched exactly, and take precedence over arguments and other
+// flags, which still use the -mask value.
+//
+// With -v (-verbose) flag the number of sanitized fields is reported to stderr
+// once input is processed.
+//
+// Output is compact by default, use -indent flag to pretty-print it: either
+// with the given indent string, or with tabs if flag value is "tab".
 package main
 
 import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
 	"os"
+	"regexp"
+	"strings"
 
 	"github.com/artyom/sanitize"
 )
 
 func main() {
-	if len(os.Args) < 2 {
-		os.Stderr.WriteString(usage)
+	args := runArgs{Mask: sanitize.Mask, Stderr: os.Stderr}
+	flag.StringVar(&args.Mask, "mask", args.Mask, "replacement `value` for sanitized fields")
+	flag.BoolVar(&args.IgnoreCase, "i", false, "match field names case-insensitively")
+	flag.BoolVar(&args.IgnoreCase, "ignore-case", false, "same as -i")
+	flag.BoolVar(&args.All, "all", false, "sanitize all string fields")
+	flag.BoolVar(&args.Regex, "regex", false, "treat arguments as regular expressions")
+	flag.BoolVar(&args.ByValue, "by-value", false, "match arguments against values instead of field names")
+	flag.BoolVar(&args.Verbose, "v", false, "report number of sanitized fields to stderr")
+	flag.BoolVar(&args.Verbose, "verbose", false, "same as -v")
+	flag.StringVar(&args.FieldsFile, "fields-file", "", "read field names from this `file`, one per line")
+	flag.StringVar(&args.MapFile, "map", "", "read field names and their replacements from this json `file`")
+	flag.StringVar(&args.Indent, "indent", "", "pretty-print output using this `string` as indent (\"tab\" for tabs)")
+	flag.Usage = func() {
+		os.Stderr.WriteString(usage + "\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	args.Keys = flag.Args()
+	if len(args.Keys) == 0 && args.FieldsFile == "" && args.MapFile == "" && !args.All {
+		flag.Usage()
 		os.Exit(2)
 	}
-	if err := run(os.Args[1:]); err != nil {
+	if err := run(args, os.Stdout, os.Stdin); err != nil {
 		os.Stderr.WriteString(err.Error() + "\n")
 		os.Exit(1)
 	}
 }
 
-func run(keys []string) error {
-	m := make(map[string]struct{}, len(keys))
-	for _, k := range keys {
-		m[k] = struct{}{}
+type runArgs struct {
+	Keys       []string
+	Mask       string
+	Indent     string
+	FieldsFile string
+	MapFile    string // json object mapping field names to replacements
+	IgnoreCase bool
+	Regex      bool
+	ByValue    bool // match Keys against values instead of keys
+	All        bool
+	Verbose    bool
+	Stderr     io.Writer // where -v report is written
+}
+
+func run(args runArgs, w io.Writer, r io.Reader) error {
+	if args.FieldsFile != "" {
+		keys, err := readFields(args.FieldsFile)
+		if err != nil {
+			return err
+		}
+		args.Keys = append(keys, args.Keys...)
+	}
+	match, err := keyMatcher(args)
+	if err != nil {
+		return err
 	}
 	fn := func(key, _ string) (string, bool) {
-		if _, ok := m[key]; ok {
-			return "REDACTED", true
+		if match(key) {
+			return args.Mask, true
 		}
 		return "", false
 	}
-	return sanitize.Stream(os.Stdout, os.Stdin, fn)
+	if args.ByValue {
+		fn = func(_, value string) (string, bool) {
+			if match(value) {
+				return args.Mask, true
+			}
+			return "", false
+		}
+	}
+	if args.All {
+		fn = sanitize.AllStrings(args.Mask)
+	}
+	if args.MapFile != "" {
+		m, err := readMap(args.MapFile)
+		if err != nil {
+			return err
+		}
+		fn = sanitize.Chain(sanitize.FromMap(m), fn)
+	}
+	var n int
+	if args.Verbose {
+		fn = countFields(fn, &n)
+	}
+	if args.Indent == "" {
+		err = sanitize.Stream(w, r, fn)
+	} else {
+		indent := args.Indent
+		if indent == "tab" {
+			indent = "\t"
+		}
+		err = sanitize.StreamIndent(w, r, fn, "", indent)
+	}
+	if err != nil {
+		return err
+	}
+	if args.Verbose {
+		fmt.Fprintf(args.Stderr, "redacted %d fields\n", n)
+	}
+	return nil
+}
+
+// countFields returns FieldFunc calling fn and incrementing n on each
+// sanitized field
+func countFields(fn sanitize.FieldFunc, n *int) sanitize.FieldFunc {
+	return func(key, value string) (string, bool) {
+		val, ok := fn(key, value)
+		if ok {
+			*n++
+		}
+		return val, ok
+	}
+}
+
+// keyMatcher returns function reporting whether field with the given key, or
+// with the given value if args.ByValue is set, should be sanitized
+func keyMatcher(args runArgs) (func(key string) bool, error) {
+	if args.Regex {
+		res := make([]*regexp.Regexp, len(args.Keys))
+		for i, k := range args.Keys {
+			if args.IgnoreCase {
+				k = "(?i)" + k
+			}
+			re, err := regexp.Compile(k)
+			if err != nil {
+				return nil, fmt.Errorf("invalid -regex pattern: %w", err)
+			}
+			res[i] = re
+		}
+		return func(key string) bool {
+			for _, re := range res {
+				if re.MatchString(key) {
+					return true
+				}
+			}
+			return false
+		}, nil
+	}
+	if args.IgnoreCase {
+		fold := sanitize.KeysFold("", args.Keys...)
+		return func(key string) bool {
+			_, ok := fold(key, "")
+			return ok
+		}, nil
+	}
+	m := make(map[string]struct{}, len(args.Keys))
+	for _, k := range args.Keys {
+		m[k] = struct{}{}
+	}
+	return func(key string) bool {
+		_, ok := m[key]
+		return ok
+	}, nil
+}
+
+// readFields reads field names from file, one per line, skipping blank lines
+// and lines starting with #
+func readFields(name string) ([]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading -fields-file: %w", err)
+	}
+	var keys []string
+	for _, line := range strings.Split(string(b), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, nil
+}
+
+// readMap reads json object mapping field names to their replacements from
+// file
+func readMap(name string) (map[string]string, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("reading -map: %w", err)
+	}
+	var m map[string]string
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("parsing -map %s: must be a json object with string values: %w", name, err)
+	}
+	return m, nil
 }
 
 //go:generate usagegen
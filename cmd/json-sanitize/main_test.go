@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestRunIgnoreCase(t *testing.T) {
+	const input = `{"Password":"a","password":"b","PASSWORD":"c","user":"d","STRA\u1e9eE":"e","\u212aey":"f"}`
+	const want = `{"Password":"REDACTED","password":"REDACTED","PASSWORD":"REDACTED","user":"d",` +
+		"\"STRA\u1e9eE\":\"REDACTED\",\"\u212aey\":\"REDACTED\"}"
+	args := runArgs{Keys: []string{"passWord", "stra\u00dfe", "key"}, Mask: "REDACTED", IgnoreCase: true}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunRegex(t *testing.T) {
+	const input = `{"apiToken":"a","refresh_token":"b","tokenize":"c","Token":"d"}`
+	const want = `{"apiToken":"REDACTED","refresh_token":"REDACTED","tokenize":"c","Token":"REDACTED"}`
+	args := runArgs{Keys: []string{".*[Tt]oken$"}, Mask: "REDACTED", Regex: true}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	args.Keys = []string{"(unclosed"}
+	if err := run(args, buf, strings.NewReader(input)); err == nil {
+		t.Fatal("invalid pattern accepted")
+	}
+}
+
+func TestRunAll(t *testing.T) {
+	const input = `{"a":"x","b":{"c":"y","d":1},"e":["z"]}`
+	const want = `{"a":"***","b":{"c":"***","d":1},"e":["z"]}`
+	args := runArgs{Mask: "***", All: true}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestRunFieldsFile(t *testing.T) {
+	const input = `{"password":"a","token":"b","user":"c","# comment":"d","ssn":"e"}`
+	const want = `{"password":"REDACTED","token":"REDACTED","user":"c","# comment":"d","ssn":"REDACTED"}`
+	f, err := ioutil.TempFile("", "json-sanitize-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString("# sensitive fields\npassword\n\n  token\r\n# comment\n"); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	args := runArgs{Keys: []string{"ssn"}, Mask: "REDACTED", FieldsFile: f.Name()}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	args.FieldsFile = f.Name() + ".missing"
+	err = run(args, buf, strings.NewReader(input))
+	if err == nil || !strings.HasPrefix(err.Error(), "reading -fields-file: ") || !os.IsNotExist(errors.Unwrap(err)) {
+		t.Fatalf("unexpected error for missing file: %v", err)
+	}
+}
+
+func TestRunVerbose(t *testing.T) {
+	const input = `{"a":"x","b":{"a":"y","c":1},"d":[{"a":"z"}]}`
+	const want = `{"a":"REDACTED","b":{"a":"REDACTED","c":1},"d":[{"a":"REDACTED"}]}`
+	stderr := new(bytes.Buffer)
+	args := runArgs{Keys: []string{"a", "c"}, Mask: "REDACTED", Verbose: true, Stderr: stderr}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := stderr.String(), "redacted 3 fields\n"; got != want {
+		t.Fatalf("got report %q, want %q", got, want)
+	}
+}
+
+func TestRunByValue(t *testing.T) {
+	const input = `{"a":"hunter2","b":{"c":"hunter2","d":"hunter22"},"hunter2":"x","e":"HUNTER2"}`
+	const want = `{"a":"REDACTED","b":{"c":"REDACTED","d":"hunter22"},"hunter2":"x","e":"HUNTER2"}`
+	args := runArgs{Keys: []string{"hunter2"}, Mask: "REDACTED", ByValue: true}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	const wantRegex = `{"a":"REDACTED","b":{"c":"REDACTED","d":"REDACTED"},"hunter2":"x","e":"REDACTED"}`
+	args.Regex, args.IgnoreCase = true, true
+	buf.Reset()
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != wantRegex {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, wantRegex)
+	}
+}
+
+func TestRunMap(t *testing.T) {
+	const input = `{"password":"a","ssn":"b","token":"c","user":"d","n":{"ssn":"e"}}`
+	const want = `{"password":"***","ssn":"XXX-XX-XXXX","token":"REDACTED","user":"d","n":{"ssn":"XXX-XX-XXXX"}}`
+	f, err := ioutil.TempFile("", "json-sanitize-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.WriteString(`{"password":"***","ssn":"XXX-XX-XXXX"}`); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	args := runArgs{Keys: []string{"token", "ssn"}, Mask: "REDACTED", MapFile: f.Name()}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	for _, bad := range []string{`{"password":1}`, `["password"]`, `{"password":`} {
+		if err := ioutil.WriteFile(f.Name(), []byte(bad), 0600); err != nil {
+			t.Fatal(err)
+		}
+		err := run(args, buf, strings.NewReader(input))
+		if err == nil || !strings.HasPrefix(err.Error(), "parsing -map ") {
+			t.Errorf("%s: unexpected error: %v", bad, err)
+		}
+	}
+}
+
+func TestRunMask(t *testing.T) {
+	const input = `{"password":"a","user":"b"}`
+	const want = `{"password":"say \"hi\" \\ \u003cb\u003e","user":"b"}`
+	args := runArgs{Keys: []string{"password"}, Mask: `say "hi" \ <b>`}
+	buf := new(bytes.Buffer)
+	if err := run(args, buf, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want || !json.Valid(buf.Bytes()) {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	var v struct{ Password string }
+	if err := json.Unmarshal(buf.Bytes(), &v); err != nil || v.Password != args.Mask {
+		t.Fatalf("got %q, %v; want %q", v.Password, err, args.Mask)
+	}
+}
+
+func TestRunIndent(t *testing.T) {
+	const input = `{"password":"a","list":[1,{"user":"b"}],"empty":{}}`
+	compact := new(bytes.Buffer)
+	if err := run(runArgs{Keys: []string{"password"}, Mask: "x"}, compact, strings.NewReader(input)); err != nil {
+		t.Fatal(err)
+	}
+	for indent, want := range map[string]string{"  ": "  ", "tab": "\t"} {
+		wantBuf := new(bytes.Buffer)
+		if err := json.Indent(wantBuf, compact.Bytes(), "", want); err != nil {
+			t.Fatal(err)
+		}
+		args := runArgs{Keys: []string{"password"}, Mask: "x", Indent: indent}
+		buf := new(bytes.Buffer)
+		if err := run(args, buf, strings.NewReader(input)); err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.TrimSuffix(buf.String(), "\n"); got != wantBuf.String() {
+			t.Fatalf("-indent %q got:\n%s\nwant:\n%s", indent, got, wantBuf)
+		}
+	}
+}
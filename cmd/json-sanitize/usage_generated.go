@@ -2,4 +2,4 @@
 
 package main
 
-const usage = "Command json-sanitize sanitizes string fields of json input replacing them with\n\"REDACTED\" value.\n\nCommand takes list of case-sensitive field names as its arguments, then reads\narbitrary json structure over stdin and writes sanitized version to stdout.\n\nFor example, the following call:\n\n\techo '{\"foo\":\"foo\", \"bar\":\"bar\"}' | json-sanitize foo\n\nwill produce this:\n\n\t{\"foo\":\"REDACTED\",\"bar\":\"bar\"}\n"
+const usage = "Command json-sanitize sanitizes string fields of json input replacing them with\n\"********\" value, the same default placeholder sanitize.Mask the library uses.\n\nCommand takes list of case-sensitive field names as its arguments, then reads\narbitrary json structure over stdin and writes sanitized version to stdout.\n\nFor example, the following call:\n\n\techo '{\"foo\":\"foo\", \"bar\":\"bar\"}' | json-sanitize foo\n\nwill produce this:\n\n\t{\"foo\":\"********\",\"bar\":\"bar\"}\n\nField names can also be read from a file given with -fields-file flag, one name\nper line; blank lines and lines starting with # are ignored. Names from the file\nare merged with names given as arguments.\n\nUse -mask flag to use another replacement value instead of \"********\".\nWith -all flag every string field is sanitized and no field names are expected.\nField names are matched case-insensitively if -i (-ignore-case) flag is set.\nWith -regex flag arguments are treated as regular expressions in Go syntax\n(https://golang.org/s/re2syntax), and field is sanitized if its name matches any\nof them. Patterns are not anchored, so use ^ and $ to match the whole name.\n\nWith -by-value flag arguments are matched against string values instead of field\nnames: field is sanitized if its value equals any of arguments, or matches any\nof them with -regex flag, regardless of its name. Names read from -fields-file\nare treated as values then, and -i flag makes values match case-insensitively.\n\nWith -map flag field names and their replacements are read from a json file\nholding an object like {\"password\":\"***\",\"ssn\":\"XXX-XX-XXXX\"}: each field\nlisted there is sanitized with its own replacement value. Names in this file are\nmatched exactly, and take precedence over arguments and other flags, which still\nuse the -mask value.\n\nWith -v (-verbose) flag the number of sanitized fields is reported to stderr\nonce input is processed.\n\nOutput is compact by default, use -indent flag to pretty-print it: either with\nthe given indent string, or with tabs if flag value is \"tab\".\n"
@@ -0,0 +1,168 @@
+package sanitize
+
+import (
+	"encoding/json"
+	"errors"
+	"math"
+	"net"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// RedactEmails returns FieldFunc that substitutes with mask values that are
+// email addresses. The whole value must be an address in the common
+// local@domain form with a dot-separated domain ending in an alphabetic
+// top-level domain, so values like "foo@bar" or "a@b@c" are kept.
+func RedactEmails(mask string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if emailRe.MatchString(value) {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+var emailRe = regexp.MustCompile(`^[A-Za-z0-9!#$%&'*+/=?^_{|}~-]+(?:\.[A-Za-z0-9!#$%&'*+/=?^_{|}~-]+)*` +
+	`@(?:[A-Za-z0-9](?:[A-Za-z0-9-]{0,61}[A-Za-z0-9])?\.)+[A-Za-z]{2,63}$`)
+
+// RedactIPs returns FieldFunc that substitutes with mask values that are IPv4
+// or IPv6 addresses, as parsed by net.ParseIP.
+func RedactIPs(mask string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if net.ParseIP(value) != nil {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+// RedactURLs returns FieldFunc that substitutes with mask values that are
+// absolute URLs with both scheme and host, like "https://example.com/path".
+// Values with whitespace are never treated as URLs.
+func RedactURLs(mask string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if isURL(value) {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+func isURL(value string) bool {
+	if strings.ContainsAny(value, " \t\r\n") {
+		return false
+	}
+	u, err := url.Parse(value)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
+
+// RedactHighEntropy returns FieldFunc that substitutes with mask values
+// containing random-looking words, a common heuristic to catch unknown
+// secrets like API tokens and keys. Value is split into words separated by
+// whitespace, and it is masked if any word of at least minLen runes has
+// Shannon entropy per rune above threshold bits. Checking words rather than
+// the whole value keeps ordinary prose, which has lots of distinct letters,
+// but catches tokens embedded in text, like "Authorization: Bearer ...".
+//
+// Entropy of a word is at most log2 of its length in runes, random base64
+// strings of 20-40 characters typically have entropy of 4-5 bits, while
+// random hex strings never exceed 4 bits. Threshold of 4 with minLen of 20
+// catches most base64 tokens; hex-encoded secrets need lower threshold and
+// larger minLen, like 3 and 32.
+func RedactHighEntropy(mask string, minLen int, threshold float64) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if utf8.RuneCountInString(value) < minLen {
+			return "", false
+		}
+		for _, w := range strings.Fields(value) {
+			if utf8.RuneCountInString(w) >= minLen && entropy(w) > threshold {
+				return mask, true
+			}
+		}
+		return "", false
+	}
+}
+
+// entropy returns Shannon entropy of s in bits per rune
+func entropy(s string) float64 {
+	counts := make(map[rune]int)
+	n := 0
+	for _, r := range s {
+		counts[r]++
+		n++
+	}
+	var h float64
+	for _, c := range counts {
+		p := float64(c) / float64(n)
+		h -= p * math.Log2(p)
+	}
+	return h
+}
+
+// RedactLargeNumbers returns NumberFunc that substitutes with mask numeric
+// values whose absolute value exceeds threshold, like monetary amounts, while
+// keeping smaller ones, like counters and flags. Numbers too large for
+// float64, like 1e400, are treated as infinite and are always substituted,
+// as is any number that fails to parse.
+func RedactLargeNumbers(mask string, threshold float64) NumberFunc {
+	return func(_ string, num json.Number) (string, bool) {
+		f, err := strconv.ParseFloat(string(num), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) || math.Abs(f) > threshold {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+// MaskWithType returns FieldFunc that substitutes every value with base
+// followed by the detected format of the value in parentheses, like
+// "********(email)", which helps to tell what kind of data was redacted.
+// Detected formats are:
+//
+//   - "email", for values RedactEmails matches;
+//   - "uuid", for UUIDs in the canonical 8-4-4-4-12 hex form;
+//   - "ipv4" and "ipv6", for IP addresses as parsed by net.ParseIP;
+//   - "url", for values RedactURLs matches;
+//   - "number", for decimal numbers with optional sign, fraction and
+//     exponent, like "42", "-1.5" or "1e10".
+//
+// Values of no detected format are substituted with plain base.
+func MaskWithType(base string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if typ := valueFormat(value); typ != "" {
+			return base + "(" + typ + ")", true
+		}
+		return base, true
+	}
+}
+
+// valueFormat returns name of the format of value detected by MaskWithType,
+// or an empty string
+func valueFormat(value string) string {
+	switch {
+	case emailRe.MatchString(value):
+		return "email"
+	case uuidRe.MatchString(value):
+		return "uuid"
+	case numberRe.MatchString(value):
+		return "number"
+	}
+	if ip := net.ParseIP(value); ip != nil {
+		if strings.IndexByte(value, ':') < 0 {
+			return "ipv4"
+		}
+		return "ipv6"
+	}
+	if isURL(value) {
+		return "url"
+	}
+	return ""
+}
+
+var (
+	uuidRe   = regexp.MustCompile(`^[0-9A-Fa-f]{8}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{4}-[0-9A-Fa-f]{12}$`)
+	numberRe = regexp.MustCompile(`^[-+]?(?:\d+(?:\.\d*)?|\.\d+)(?:[eE][-+]?\d+)?$`)
+)
@@ -0,0 +1,72 @@
+package sanitize
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ErrTrailingData is returned by StreamSingle when input has more data after
+// the first json value.
+var ErrTrailingData = errors.New("sanitize: trailing data after json value")
+
+// SyntaxError is returned when input is not a valid json. Use errors.As to
+// get it from the error returned by Stream or Message.
+//
+// Output produced before the malformed part of input is still written: Stream
+// and similar functions flush it to the writer before returning the error.
+type SyntaxError struct {
+	Err    error // underlying error, *json.SyntaxError or io.ErrUnexpectedEOF
+	Offset int64 // input offset after the last successfully read token
+
+	// Path is the location within payload where input broke, starting from
+	// the top-level object or array. Last element is the member or element
+	// being read; if object member key is not read yet, Path ends with the
+	// object itself.
+	Path []PathElem
+}
+
+func (e *SyntaxError) Error() string {
+	var b strings.Builder
+	b.WriteString("sanitize: syntax error at offset ")
+	b.WriteString(strconv.FormatInt(e.Offset, 10))
+	if len(e.Path) != 0 {
+		b.WriteString(" in ")
+		for _, p := range e.Path {
+			if p.Index >= 0 {
+				b.WriteByte('[')
+				b.WriteString(strconv.Itoa(p.Index))
+				b.WriteByte(']')
+				continue
+			}
+			b.WriteByte('.')
+			b.WriteString(p.Key)
+		}
+	}
+	b.WriteString(": ")
+	b.WriteString(e.Err.Error())
+	return b.String()
+}
+
+func (e *SyntaxError) Unwrap() error { return e.Err }
+
+// syntaxError wraps err into *SyntaxError if it is caused by malformed input;
+// other errors, like failed reads, are returned as is
+func (s *state) syntaxError(err error) error {
+	if _, ok := err.(*json.SyntaxError); !ok && err != io.ErrUnexpectedEOF {
+		return err
+	}
+	path := make([]PathElem, len(s.path))
+	copy(path, s.path)
+	if n := len(s.stack); n > 0 && s.skip == 0 {
+		switch top := s.stack[n-1]; {
+		case top.delim == '[':
+			path[n-1] = PathElem{Index: top.n}
+		case !top.value:
+			path = path[:n-1]
+		}
+	}
+	return &SyntaxError{Err: err, Offset: s.base + s.tok.offset(), Path: path}
+}
@@ -0,0 +1,76 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestSyntaxError(t *testing.T) {
+	for _, tc := range []struct {
+		input   string
+		offset  int64
+		path    []sanitize.PathElem
+		partial string
+		eof     bool
+	}{
+		{`{"a":1,}`, 6, nil, `{"a":1`, false},
+		{`{"a":[1,2 3]}`, 9, []sanitize.PathElem{{Key: "a", Index: -1}, {Index: 2}}, `{"a":[1,2`, false},
+		{`{"a":{"b":x}}`, 9, []sanitize.PathElem{{Key: "a", Index: -1}, {Key: "b", Index: -1}}, `{"a":{`, false},
+		{`[{"a":"b"},{"c":`, 16, []sanitize.PathElem{{Index: 1}, {Key: "c", Index: -1}}, `[{"a":"********"},{`, true},
+	} {
+		buf := new(bytes.Buffer)
+		err := sanitize.Stream(buf, strings.NewReader(tc.input), fn)
+		var serr *sanitize.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%s: unexpected error: %v", tc.input, err)
+			continue
+		}
+		if serr.Offset != tc.offset {
+			t.Errorf("%s: got offset %d, want %d", tc.input, serr.Offset, tc.offset)
+		}
+		if len(serr.Path) != 0 || len(tc.path) != 0 {
+			if !reflect.DeepEqual(serr.Path, tc.path) {
+				t.Errorf("%s: got path %v, want %v", tc.input, serr.Path, tc.path)
+			}
+		}
+		if got := errors.Is(err, io.ErrUnexpectedEOF); got != tc.eof {
+			t.Errorf("%s: errors.Is(err, io.ErrUnexpectedEOF) = %v", tc.input, got)
+		}
+		var jerr *json.SyntaxError
+		if got := errors.As(err, &jerr); got == tc.eof {
+			t.Errorf("%s: errors.As(err, *json.SyntaxError) = %v", tc.input, got)
+		}
+		if got := buf.String(); got != tc.partial {
+			t.Errorf("%s: got partial output %q, want %q", tc.input, got, tc.partial)
+		}
+	}
+}
+
+func TestSyntaxErrorMessage(t *testing.T) {
+	_, err := sanitize.Message(nil, []byte(`{"a":[{"b":"c"},{"d":tru}]}`), fn)
+	const want = "sanitize: syntax error at offset 20 in .a[1].d: invalid character '}' in literal true (expecting 'e')"
+	if err == nil || err.Error() != want {
+		t.Fatalf("got error %v, want %s", err, want)
+	}
+}
+
+func TestReadErrorNotWrapped(t *testing.T) {
+	errRead := errors.New("read failed")
+	r := io.MultiReader(strings.NewReader(`{"a":`), &errReader{errRead})
+	err := sanitize.Stream(ioutil.Discard, r, fn)
+	if err != errRead {
+		t.Fatalf("got error %v, want %v", err, errRead)
+	}
+}
+
+type errReader struct{ err error }
+
+func (r *errReader) Read([]byte) (int, error) { return 0, r.err }
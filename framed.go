@@ -0,0 +1,76 @@
+package sanitize
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// ErrTruncatedFrame is returned by StreamFramed when input ends in the middle
+// of a frame.
+var ErrTruncatedFrame = errors.New("sanitize: truncated frame")
+
+// StreamFramed sanitizes a sequence of length-prefixed frames read from r
+// writing result to w. Each frame is a 4-byte big-endian length followed by
+// that many bytes of json payload, which is sanitized the same way Message
+// does. Each sanitized payload is written to w as a new frame, with the
+// length prefix recomputed, as sanitized payload length usually differs from
+// the original.
+//
+// Input ending on a frame boundary, including empty input, is processed
+// successfully; if it ends within a frame, StreamFramed returns an error
+// wrapping ErrTruncatedFrame. If frame payload is not a valid json,
+// StreamFramed returns *SyntaxError with offset within that payload. Frames
+// sanitized before an error are flushed to w.
+func StreamFramed(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	bw := bufio.NewWriter(w)
+	var in bytes.Buffer
+	var out []byte
+	var hdr [4]byte
+	for n := 0; ; n++ {
+		if _, err := io.ReadFull(r, hdr[:]); err != nil {
+			if err == io.EOF {
+				return bw.Flush()
+			}
+			bw.Flush()
+			return frameError(n, err)
+		}
+		size := binary.BigEndian.Uint32(hdr[:])
+		in.Reset()
+		// frame is copied rather than read into a buffer of its size, so
+		// bogus lengths don't cause huge allocations
+		if _, err := io.CopyN(&in, r, int64(size)); err != nil {
+			bw.Flush()
+			return frameError(n, err)
+		}
+		var err error
+		if out, err = Message(out, in.Bytes(), fn); err != nil {
+			bw.Flush()
+			return err
+		}
+		if uint64(len(out)) > math.MaxUint32 {
+			bw.Flush()
+			return fmt.Errorf("sanitize: frame %d: sanitized payload of %d bytes is too large", n, len(out))
+		}
+		binary.BigEndian.PutUint32(hdr[:], uint32(len(out)))
+		bw.Write(hdr[:])
+		if _, err := bw.Write(out); err != nil {
+			return err
+		}
+	}
+}
+
+// frameError returns error of reading frame number n
+func frameError(n int, err error) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("%w: frame %d", ErrTruncatedFrame, n)
+	}
+	return err
+}
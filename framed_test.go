@@ -0,0 +1,58 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamFramed(t *testing.T) {
+	var in, wantFrames bytes.Buffer
+	for _, s := range []string{input, `{"Msg":"a"}`, `"x"`} {
+		writeFrame(&in, s)
+	}
+	for _, s := range []string{want, `{"Msg":"********"}`, `"x"`} {
+		writeFrame(&wantFrames, s)
+	}
+	out := new(bytes.Buffer)
+	if err := sanitize.StreamFramed(out, &in, fn); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out.Bytes(), wantFrames.Bytes()) {
+		t.Fatalf("got:\n%q\nwant:\n%q", out.Bytes(), wantFrames.Bytes())
+	}
+	out.Reset()
+	if err := sanitize.StreamFramed(out, new(bytes.Buffer), fn); err != nil || out.Len() != 0 {
+		t.Fatalf("empty input: got %q, %v", out.Bytes(), err)
+	}
+}
+
+func TestStreamFramedErrors(t *testing.T) {
+	var frame bytes.Buffer
+	writeFrame(&frame, `{"Msg":"a"}`)
+	for _, n := range []int{2, 4, frame.Len() - 1} {
+		in := bytes.NewBuffer(append(append([]byte(nil), frame.Bytes()...), frame.Bytes()[:n]...))
+		out := new(bytes.Buffer)
+		err := sanitize.StreamFramed(out, in, fn)
+		if !errors.Is(err, sanitize.ErrTruncatedFrame) {
+			t.Errorf("%d bytes of the last frame: got %v, want ErrTruncatedFrame", n, err)
+		}
+		if out.Len() != len(`{"Msg":"********"}`)+4 {
+			t.Errorf("%d bytes of the last frame: first frame not flushed: %q", n, out.Bytes())
+		}
+	}
+	var bad bytes.Buffer
+	writeFrame(&bad, `{"Msg":`)
+	var serr *sanitize.SyntaxError
+	if err := sanitize.StreamFramed(new(bytes.Buffer), &bad, fn); !errors.As(err, &serr) {
+		t.Fatalf("got %v, want *SyntaxError", err)
+	}
+}
+
+func writeFrame(b *bytes.Buffer, payload string) {
+	binary.Write(b, binary.BigEndian, uint32(len(payload)))
+	b.WriteString(payload)
+}
@@ -0,0 +1,356 @@
+package sanitize
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"regexp"
+	"strconv"
+)
+
+// Field describes scalar value of json payload along with its location.
+// Value is either a member of an object, or an element of an array, or the
+// top-level value itself, which has an empty Key and Path.
+//
+// All the information is tracked on the stack of currently open objects and
+// arrays as payload is decoded, so it is available at the constant cost per
+// value and without extra allocations.
+type Field struct {
+	Key   string // key value is stored under, empty for array elements
+	Value string // decoded string, or json text of other kinds of values
+	Kind  Kind
+
+	// Depth is the nesting level of the value: 1 for members of the
+	// top-level object, 2 for members of objects nested in it, and so on.
+	// Arrays also count as a nesting level.
+	Depth int
+
+	// ParentKey is the closest key object or array holding the value is
+	// nested under, possibly through other arrays. For {"a":[{"b":"c"}]}
+	// ParentKey of value "c" is "a". ParentKey is empty for values of the
+	// top-level object or array.
+	ParentKey string
+
+	// Index is the position of array element, or -1 for object members.
+	Index int
+
+	// Prev is the original value of the preceding array element if it was
+	// a string; it is empty for the first element, or if the preceding
+	// element was of another type. Only the last string element is kept
+	// per each open array, which is enough to implement rules like
+	// "redact element following the marker".
+	Prev string
+
+	// Path is the location of the value starting from the top-level
+	// object or array, the last element of Path describes the value
+	// itself. Path is only valid during the call and must be copied if
+	// retained.
+	Path []PathElem
+
+	// Offset is the input offset right after the value, as reported by
+	// json.Decoder.InputOffset: for strings it is the position following
+	// the closing quote. It counts bytes from the very beginning of input,
+	// including byte order mark if any.
+	Offset int64
+}
+
+// Func is called on each scalar value of object members and array elements
+// processed by MessageFunc or StreamFunc. If function returns true for mask,
+// value is substituted by newValue.
+//
+// String values are always replaced with strings. Values of other kinds are
+// replaced with newValue written verbatim if it is a valid json number, true,
+// false or null; any other newValue is written as a json string, so replacing
+// true with "********" produces a valid json.
+type Func func(f Field) (newValue string, mask bool)
+
+// Kind is a kind of json scalar value.
+type Kind uint8
+
+// Kinds of json values passed to Func.
+const (
+	String Kind = iota
+	Number
+	Bool
+	Null
+
+	// Object and Array kinds are never passed to Func, they are only
+	// reported to Options.Drop for object members holding objects and
+	// arrays. Field.Value is "{" or "[" for them.
+	Object
+	Array
+)
+
+func (k Kind) String() string {
+	switch k {
+	case String:
+		return "string"
+	case Number:
+		return "number"
+	case Bool:
+		return "bool"
+	case Null:
+		return "null"
+	case Object:
+		return "object"
+	case Array:
+		return "array"
+	}
+	return "Kind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// Funcs returns Func calling each of fns in order until one of them returns
+// true for mask.
+func Funcs(fns ...Func) Func {
+	return func(f Field) (string, bool) {
+		for _, fn := range fns {
+			if val, ok := fn(f); ok {
+				return val, true
+			}
+		}
+		return "", false
+	}
+}
+
+// NumberFunc is called on each numeric attribute of JSON object. Arguments
+// provided are key/value pair of JSON payload, if function returns true for
+// mask, attribute value is substituted by newValue: as is if it is a valid
+// json number, or as a json string otherwise.
+type NumberFunc func(key string, num json.Number) (newValue string, mask bool)
+
+// Func returns Func calling fn on numeric values of object members.
+func (fn NumberFunc) Func() Func {
+	return func(f Field) (string, bool) {
+		if f.Index >= 0 || f.Kind != Number {
+			return "", false
+		}
+		return fn(f.Key, json.Number(f.Value))
+	}
+}
+
+// DepthFunc is called on each string attribute of JSON object with its
+// nesting depth, see Field.Depth. If function returns true for mask,
+// attribute value is substituted by newValue.
+type DepthFunc func(key, value string, depth int) (newValue string, mask bool)
+
+// Func returns Func calling fn on string values of object members.
+func (fn DepthFunc) Func() Func { return fn.field }
+
+func (fn DepthFunc) field(f Field) (string, bool) {
+	if f.Index >= 0 || f.Kind != String {
+		return "", false
+	}
+	return fn(f.Key, f.Value, f.Depth)
+}
+
+// StreamDepth is a variant of Stream that calls fn with nesting depth of each
+// value.
+func StreamDepth(w io.Writer, r io.Reader, fn DepthFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	return StreamFunc(w, r, fn.field)
+}
+
+// MessageDepth is a variant of Message that calls fn with nesting depth of
+// each value.
+func MessageDepth(dst, src []byte, fn DepthFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	return MessageFunc(dst, src, fn.field)
+}
+
+// OffsetFunc is called on each string attribute of JSON object with its
+// offset in the input, see Field.Offset for its meaning: it points right
+// after the closing quote of the value. If function returns true for mask,
+// attribute value is substituted by newValue.
+type OffsetFunc func(key, value string, offset int64) (newValue string, mask bool)
+
+// Func returns Func calling fn on string values of object members.
+func (fn OffsetFunc) Func() Func { return fn.field }
+
+func (fn OffsetFunc) field(f Field) (string, bool) {
+	if f.Index >= 0 || f.Kind != String {
+		return "", false
+	}
+	return fn(f.Key, f.Value, f.Offset)
+}
+
+// StreamOffset is a variant of Stream that calls fn with input offset of
+// each value.
+func StreamOffset(w io.Writer, r io.Reader, fn OffsetFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	return StreamFunc(w, r, fn.field)
+}
+
+// MessageOffset is a variant of Message that calls fn with input offset of
+// each value.
+func MessageOffset(dst, src []byte, fn OffsetFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	return MessageFunc(dst, src, fn.field)
+}
+
+// Matcher is a predicate over string value of object member and its location,
+// see Field for the meaning of arguments. Matcher is never called for array
+// elements.
+type Matcher func(depth int, parentKey, key, value string) bool
+
+// And returns Matcher that matches when both m and other match.
+func (m Matcher) And(other Matcher) Matcher {
+	return func(depth int, parentKey, key, value string) bool {
+		return m(depth, parentKey, key, value) && other(depth, parentKey, key, value)
+	}
+}
+
+// Or returns Matcher that matches when either m or other match.
+func (m Matcher) Or(other Matcher) Matcher {
+	return func(depth int, parentKey, key, value string) bool {
+		return m(depth, parentKey, key, value) || other(depth, parentKey, key, value)
+	}
+}
+
+// Not returns Matcher that matches when m does not.
+func (m Matcher) Not() Matcher {
+	return func(depth int, parentKey, key, value string) bool {
+		return !m(depth, parentKey, key, value)
+	}
+}
+
+// Mask returns Func that substitutes values matched by m with mask. For
+// example, to only redact "id" and "ref" members holding values that look
+// like card numbers:
+//
+//	KeyIn("id", "ref").And(ValueMatches(cardRe)).Mask(Mask)
+func (m Matcher) Mask(mask string) Func {
+	return func(f Field) (string, bool) {
+		if f.Index < 0 && f.Kind == String && m(f.Depth, f.ParentKey, f.Key, f.Value) {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+// KeyIn returns Matcher that matches members with any of keys.
+func KeyIn(keys ...string) Matcher {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return func(_ int, _, key, _ string) bool {
+		_, ok := set[key]
+		return ok
+	}
+}
+
+// ValueMatches returns Matcher that matches members with values matching re.
+// Use ^ and $ to match the whole value.
+func ValueMatches(re *regexp.Regexp) Matcher {
+	return func(_ int, _, _, value string) bool { return re.MatchString(value) }
+}
+
+// RedactSubtree returns Func that substitutes with mask every string value
+// stored under any of keys, including all string values of objects and arrays
+// nested under such keys at any depth.
+func RedactSubtree(mask string, keys ...string) Func {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[k] = struct{}{}
+	}
+	return func(f Field) (string, bool) {
+		if f.Kind != String {
+			return "", false
+		}
+		for _, p := range f.Path {
+			if p.Index >= 0 {
+				continue
+			}
+			if _, ok := set[p.Key]; ok {
+				return mask, true
+			}
+		}
+		return "", false
+	}
+}
+
+// FieldFuncE is a variant of FieldFunc that can fail. Once it returns
+// a non-nil error, processing stops and that error is returned by MessageE
+// or StreamE as is, without wrapping.
+type FieldFuncE func(key, value string) (newValue string, mask bool, err error)
+
+// bind returns Func calling fn on string values of object members, which
+// records the first error of fn in s
+func (fn FieldFuncE) bind(s *state) Func {
+	return func(f Field) (string, bool) {
+		if f.Index >= 0 || f.Kind != String {
+			return "", false
+		}
+		val, ok, err := fn(f.Key, f.Value)
+		if err != nil {
+			s.fnErr = err
+			return "", false
+		}
+		return val, ok
+	}
+}
+
+// StreamE is a variant of Stream that stops once fn returns an error, and
+// returns that error. Output produced up to, but not including, the value fn
+// failed on is flushed to w, so it is not a valid json.
+func StreamE(w io.Writer, r io.Reader, fn FieldFuncE) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(nil, nil)
+	s.fn = fn.bind(s)
+	return stream(w, r, s)
+}
+
+// MessageE is a variant of Message that stops once fn returns an error, and
+// returns that error and no output.
+func MessageE(dst, src []byte, fn FieldFuncE) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	s := newState(nil, nil)
+	s.fn = fn.bind(s)
+	return message(dst, src, s)
+}
+
+// ContextFieldFunc is a variant of FieldFunc that also gets the context
+// passed to StreamContextFunc or MessageContext, so it can use request-scoped
+// values, like a tenant the payload belongs to, without resorting to globals.
+type ContextFieldFunc func(ctx context.Context, key, value string) (newValue string, mask bool)
+
+// bind returns Func calling fn with ctx on string values of object members
+func (fn ContextFieldFunc) bind(ctx context.Context) Func {
+	return FieldFunc(func(key, value string) (string, bool) {
+		return fn(ctx, key, value)
+	}).field
+}
+
+// StreamContextFunc is a variant of StreamContext that passes ctx to fn.
+func StreamContextFunc(ctx context.Context, w io.Writer, r io.Reader, fn ContextFieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.bind(ctx), nil)
+	s.ctx = ctx
+	return stream(w, r, s)
+}
+
+// MessageContext is a variant of Message that passes ctx to fn. Like
+// StreamContext, it stops processing once ctx is canceled, returning
+// ctx.Err().
+func MessageContext(ctx context.Context, dst, src []byte, fn ContextFieldFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	s := newState(fn.bind(ctx), nil)
+	s.ctx = ctx
+	return message(dst, src, s)
+}
@@ -0,0 +1,324 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestMatcher(t *testing.T) {
+	const input = `{"value":"a","config":{"value":"b","list":[{"value":"c"}],"other":{"value":"d"}},"x":{"config":{"value":"e"}}}`
+	const want = `{"value":"a","config":{"value":"*","list":[{"value":"c"}],"other":{"value":"d"}},"x":{"config":{"value":"*"}}}`
+	depth := sanitize.Matcher(func(depth int, _, _, _ string) bool { return depth >= 2 })
+	parent := sanitize.Matcher(func(_ int, parentKey, key, _ string) bool {
+		return parentKey == "config" && key == "value"
+	})
+	dst, err := sanitize.MessageFunc(nil, []byte(input), depth.And(parent).Mask("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMatcherCompose(t *testing.T) {
+	const input = `{"id":"4242424242424242","ref":"abc","note":"4242424242424242","token":"t","pin":"1234"}`
+	card := sanitize.ValueMatches(regexp.MustCompile(`^\d{16}$`))
+	for _, tc := range []struct {
+		name string
+		m    sanitize.Matcher
+		want string
+	}{
+		{"and", sanitize.KeyIn("id", "ref").And(card),
+			`{"id":"*","ref":"abc","note":"4242424242424242","token":"t","pin":"1234"}`},
+		{"or", sanitize.KeyIn("token").Or(card),
+			`{"id":"*","ref":"abc","note":"*","token":"*","pin":"1234"}`},
+		{"not", sanitize.KeyIn("id", "ref", "note").Not(),
+			`{"id":"4242424242424242","ref":"abc","note":"4242424242424242","token":"*","pin":"*"}`},
+		{"nested", sanitize.KeyIn("pin").Or(sanitize.KeyIn("id", "note").And(card.Not())),
+			`{"id":"4242424242424242","ref":"abc","note":"4242424242424242","token":"t","pin":"*"}`},
+	} {
+		dst, err := sanitize.MessageFunc(nil, []byte(input), tc.m.Mask("*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%s got:\n%s\nwant:\n%s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestField(t *testing.T) {
+	const input = `{"a":"1","b":{"c":"2","d":[{"e":"3"}]}}`
+	want := []sanitize.Field{
+		{Key: "a", Value: "1", Depth: 1, Index: -1, Offset: 8},
+		{Key: "c", Value: "2", Depth: 2, ParentKey: "b", Index: -1, Offset: 21},
+		{Key: "e", Value: "3", Depth: 4, ParentKey: "d", Index: -1, Offset: 35},
+	}
+	var got []sanitize.Field
+	fn := func(f sanitize.Field) (string, bool) {
+		f.Path = nil
+		got = append(got, f)
+		return "", false
+	}
+	if _, err := sanitize.MessageFunc(nil, []byte(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", got, want)
+	}
+}
+
+func TestFieldArrayPosition(t *testing.T) {
+	const input = `{"creds":["user","pass","secret","host",["pass"],"x",1,"pass","y"]}`
+	const want = `{"creds":["user","pass","***","host",["pass"],"x",1,"pass","***"]}`
+	fn := func(f sanitize.Field) (string, bool) {
+		if f.Index > 0 && f.Prev == "pass" {
+			return "***", true
+		}
+		return "", false
+	}
+	dst, err := sanitize.MessageFunc(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	const input = `{"a":"1","b":{"c":[{"d":"2"},["3"]]}}`
+	want := [][]sanitize.PathElem{
+		{{Key: "a", Index: -1}},
+		{{Key: "b", Index: -1}, {Key: "c", Index: -1}, {Index: 0}, {Key: "d", Index: -1}},
+		{{Key: "b", Index: -1}, {Key: "c", Index: -1}, {Index: 1}, {Index: 0}},
+	}
+	var got [][]sanitize.PathElem
+	fn := func(f sanitize.Field) (string, bool) {
+		got = append(got, append([]sanitize.PathElem(nil), f.Path...))
+		return "", false
+	}
+	if _, err := sanitize.MessageFunc(nil, []byte(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, want)
+	}
+}
+
+func TestRedactSubtree(t *testing.T) {
+	const input = `{"name":"x","secrets":{"a":"1","b":[{"c":"2"},"3",4],"d":{"secrets":"5"}},"list":[{"secrets":["6"]}],"n":"7"}`
+	const want = `{"name":"x","secrets":{"a":"*","b":[{"c":"*"},"*",4],"d":{"secrets":"*"}},"list":[{"secrets":["*"]}],"n":"7"}`
+	dst, err := sanitize.MessageFunc(nil, []byte(input), sanitize.RedactSubtree("*", "secrets"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestNumberFunc(t *testing.T) {
+	const input = `{"phone":15551234567,"balance":-12.5e3,"id":7,"odd":1,"list":[15551234567],"Msg":"Hi"}`
+	const want = `{"phone":"********","balance":0,"id":7,"odd":"1.2.3","list":[15551234567],"Msg":"********"}`
+	nfn := func(key string, num json.Number) (string, bool) {
+		switch key {
+		case "phone":
+			return sanitize.Mask, true
+		case "balance":
+			return "0", true
+		case "odd":
+			return "1.2.3", true
+		}
+		return "", false
+	}
+	fn := sanitize.Funcs(sanitize.FieldFunc(fn).Func(), sanitize.NumberFunc(nfn).Func())
+	dst, err := sanitize.MessageFunc(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dst) {
+		t.Fatal("invalid output:", string(dst))
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFuncKinds(t *testing.T) {
+	const input = `{"isVip":true,"ssnOnFile":null,"flags":[false,null],"keep":true,"n":1}`
+	const want = `{"isVip":"********","ssnOnFile":false,"flags":["********","********"],"keep":true,"n":1}`
+	var kinds []sanitize.Kind
+	fn := func(f sanitize.Field) (string, bool) {
+		kinds = append(kinds, f.Kind)
+		switch {
+		case f.Key == "ssnOnFile":
+			return "false", true
+		case f.Kind == sanitize.Bool && f.Key != "keep", f.Kind == sanitize.Null:
+			return sanitize.Mask, true
+		}
+		return "", false
+	}
+	dst, err := sanitize.MessageFunc(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dst) {
+		t.Fatal("invalid output:", string(dst))
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	wantKinds := []sanitize.Kind{sanitize.Bool, sanitize.Null, sanitize.Bool,
+		sanitize.Null, sanitize.Bool, sanitize.Number}
+	if !reflect.DeepEqual(kinds, wantKinds) {
+		t.Fatalf("got kinds %v, want %v", kinds, wantKinds)
+	}
+}
+
+func TestDepthFunc(t *testing.T) {
+	const input = `{"token":"a","user":{"token":"b","sessions":[{"token":"c"}]},"list":[[{"token":"d"}]]}`
+	const want = `{"token":"***","user":{"token":"b","sessions":[{"token":"c"}]},"list":[[{"token":"d"}]]}`
+	depths := make(map[string]int)
+	fn := func(key, value string, depth int) (string, bool) {
+		depths[value] = depth
+		if key == "token" && depth == 1 {
+			return "***", true
+		}
+		return "", false
+	}
+	dst, err := sanitize.MessageDepth(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := map[string]int{"a": 1, "b": 2, "c": 4, "d": 4}; !reflect.DeepEqual(depths, want) {
+		t.Fatalf("got depths %v, want %v", depths, want)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamDepth(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("StreamDepth got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOffsetFunc(t *testing.T) {
+	const input = "\xef\xbb\xbf{\"a\": \"x\" , \"b\":{\"c\":\n\"yy\"},\"d\":[{\"e\":\"zzz\"}], \"n\":1}"
+	for _, stream := range []bool{false, true} {
+		offsets := make(map[string]int64)
+		fn := func(key, value string, offset int64) (string, bool) {
+			offsets[value] = offset
+			return "", false
+		}
+		var err error
+		if stream {
+			err = sanitize.StreamOffset(ioutil.Discard, strings.NewReader(input), fn)
+		} else {
+			_, err = sanitize.MessageOffset(nil, []byte(input), fn)
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(offsets) != 3 {
+			t.Fatalf("stream %v: got offsets %v, want 3 values", stream, offsets)
+		}
+		for v, off := range offsets {
+			if !strings.HasSuffix(input[:off], `"`+v+`"`) {
+				t.Errorf("stream %v: offset %d of %q points to %q", stream, off, v, input[:off])
+			}
+		}
+	}
+}
+
+func TestFieldFuncE(t *testing.T) {
+	errFailed := errors.New("failed")
+	var calls int
+	fn := func(key, value string) (string, bool, error) {
+		calls++
+		switch value {
+		case "fail":
+			return "", false, errFailed
+		case "secret":
+			return "***", true, nil
+		}
+		return "", false, nil
+	}
+	const input = `{"a":"secret","b":["fail"],"c":{"d":"x"}}`
+	const want = `{"a":"***","b":["fail"],"c":{"d":"x"}}`
+	dst, err := sanitize.MessageE(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	const bad = `{"a":"secret","b":{"c":"fail","d":"x"},"e":"y"}`
+	calls = 0
+	dst, err = sanitize.MessageE(nil, []byte(bad), fn)
+	if err != errFailed || dst != nil {
+		t.Fatalf("got %q, %v, want nil output and errFailed", dst, err)
+	}
+	if calls != 2 {
+		t.Fatalf("fn called %d times after error, want 2 calls total", calls)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamE(buf, strings.NewReader(bad), fn); err != errFailed {
+		t.Fatalf("got %v, want errFailed", err)
+	}
+	if got, want := buf.String(), `{"a":"***","b":{"c":`; got != want {
+		t.Fatalf("got partial output %q, want %q", got, want)
+	}
+}
+
+type tenantKey struct{}
+
+func TestContextFieldFunc(t *testing.T) {
+	const input = `{"tenant":"x","email":"bob@example.com","list":[{"email":"amy@example.com"}]}`
+	fn := func(ctx context.Context, key, value string) (string, bool) {
+		if key == "email" && ctx.Value(tenantKey{}) == "acme" {
+			return "acme-redacted", true
+		}
+		return "", false
+	}
+	for _, tc := range []struct {
+		tenant, want string
+	}{
+		{"acme", `{"tenant":"x","email":"acme-redacted","list":[{"email":"acme-redacted"}]}`},
+		{"other", input},
+	} {
+		ctx := context.WithValue(context.Background(), tenantKey{}, tc.tenant)
+		dst, err := sanitize.MessageContext(ctx, nil, []byte(input), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Fatalf("%s: got:\n%s\nwant:\n%s", tc.tenant, got, tc.want)
+		}
+		buf := new(bytes.Buffer)
+		if err := sanitize.StreamContextFunc(ctx, buf, strings.NewReader(input), fn); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Fatalf("%s: StreamContextFunc got:\n%s\nwant:\n%s", tc.tenant, got, tc.want)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := sanitize.MessageContext(ctx, nil, []byte(input), fn); err != context.Canceled {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+}
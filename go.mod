@@ -1,3 +1,3 @@
 module github.com/artyom/sanitize
 
-go 1.12
+go 1.14
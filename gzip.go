@@ -0,0 +1,24 @@
+package sanitize
+
+import (
+	"compress/gzip"
+	"io"
+)
+
+// StreamGzip is a variant of Stream that writes gzip-compressed output to w.
+// Compressed stream is always finalized before StreamGzip returns, even on
+// error, so w receives a complete gzip stream holding all output produced;
+// w itself is not closed.
+func StreamGzip(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	zw := gzip.NewWriter(w)
+	// Stream flushes its buffered output before returning, so everything
+	// is already passed to zw by the time it is closed
+	err := Stream(zw, r, fn)
+	if cerr := zw.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
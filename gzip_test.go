@@ -0,0 +1,63 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamGzip(t *testing.T) {
+	// large enough input for output to span several internal buffers
+	var b strings.Builder
+	b.WriteByte('[')
+	for i := 0; i < 5000; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(input)
+	}
+	b.WriteByte(']')
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamGzip(buf, strings.NewReader(b.String()), fn); err != nil {
+		t.Fatal(err)
+	}
+	zr, err := gzip.NewReader(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	out, err := ioutil.ReadAll(zr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []json.RawMessage
+	if err := json.Unmarshal(out, &got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 5000 {
+		t.Fatalf("got %d elements, want 5000", len(got))
+	}
+	for i, v := range got {
+		if string(v) != want {
+			t.Fatalf("element %d got:\n%s\nwant:\n%s", i, v, want)
+		}
+	}
+	// on error output is still a complete gzip stream
+	buf.Reset()
+	if err := sanitize.StreamGzip(buf, strings.NewReader(`{"Msg":"x","a":[1,`), fn); err == nil {
+		t.Fatal("truncated input processed without error")
+	}
+	if zr, err = gzip.NewReader(buf); err != nil {
+		t.Fatal(err)
+	}
+	if out, err = ioutil.ReadAll(zr); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(out), `{"Msg":"********","a":[1`; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
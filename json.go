@@ -4,15 +4,11 @@
 
 package sanitize
 
-import (
-	"bufio"
-	"unicode/utf8"
-)
+import "unicode/utf8"
 
 // this an adapted copy of json.encodeState.string method from
 // encoding/json/encode.go
-func writeEscapedString(w *bufio.Writer, s string) {
-	const escapeHTML = true
+func writeEscapedString(w writer, s string, escapeHTML bool) {
 	start := 0
 	for i := 0; i < len(s); {
 		if b := s[i]; b < utf8.RuneSelf {
@@ -84,75 +80,66 @@ func writeEscapedString(w *bufio.Writer, s string) {
 	}
 }
 
-// this an adapted copy of json.encodeState.string method from
-// encoding/json/encode.go
-func appendEscapedString(buf []byte, s string) []byte {
-	const escapeHTML = true
-	start := 0
-	for i := 0; i < len(s); {
-		if b := s[i]; b < utf8.RuneSelf {
-			if htmlSafeSet[b] || (!escapeHTML && safeSet[b]) {
-				i++
-				continue
-			}
-			if start < i {
-				buf = append(buf, s[start:i]...)
-			}
-			switch b {
-			case '\\', '"':
-				buf = append(buf, '\\', b)
-			case '\n':
-				buf = append(buf, '\\', 'n')
-			case '\r':
-				buf = append(buf, '\\', 'r')
-			case '\t':
-				buf = append(buf, '\\', 't')
-			default:
-				// This encodes bytes < 0x20 except for \t, \n and \r.
-				// If escapeHTML is set, it also escapes <, >, and &
-				// because they can lead to security holes when
-				// user-controlled strings are rendered into JSON
-				// and served to some browsers.
-				buf = append(buf, `\u00`...)
-				buf = append(buf, hex[b>>4], hex[b&0xF])
-			}
-			i++
-			start = i
-			continue
+// isValidNumber reports whether s is a valid JSON number literal.
+//
+// this is a copy of isValidNumber function from encoding/json/encode.go
+func isValidNumber(s string) bool {
+	// This function implements the JSON numbers grammar.
+	// See https://tools.ietf.org/html/rfc7159#section-6
+	// and https://www.json.org/img/number.png
+
+	if s == "" {
+		return false
+	}
+
+	// Optional -
+	if s[0] == '-' {
+		s = s[1:]
+		if s == "" {
+			return false
 		}
-		c, size := utf8.DecodeRuneInString(s[i:])
-		if c == utf8.RuneError && size == 1 {
-			if start < i {
-				buf = append(buf, s[start:i]...)
-			}
-			buf = append(buf, `\ufffd`...)
-			i += size
-			start = i
-			continue
+	}
+
+	// Digits
+	switch {
+	default:
+		return false
+
+	case s[0] == '0':
+		s = s[1:]
+
+	case '1' <= s[0] && s[0] <= '9':
+		s = s[1:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
 		}
-		// U+2028 is LINE SEPARATOR.
-		// U+2029 is PARAGRAPH SEPARATOR.
-		// They are both technically valid characters in JSON strings,
-		// but don't work in JSONP, which has to be evaluated as JavaScript,
-		// and can lead to security holes there. It is valid JSON to
-		// escape them, so we do so unconditionally.
-		// See http://timelessrepo.com/json-isnt-a-javascript-subset for discussion.
-		if c == '\u2028' || c == '\u2029' {
-			if start < i {
-				buf = append(buf, s[start:i]...)
-			}
-			buf = append(buf, `\u202`...)
-			buf = append(buf, hex[c&0xF])
-			i += size
-			start = i
-			continue
+	}
+
+	// . followed by 1 or more digits.
+	if len(s) >= 2 && s[0] == '.' && '0' <= s[1] && s[1] <= '9' {
+		s = s[2:]
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
 		}
-		i += size
 	}
-	if start < len(s) {
-		buf = append(buf, s[start:]...)
+
+	// e or E followed by an optional - or + and
+	// 1 or more digits.
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+			if s == "" {
+				return false
+			}
+		}
+		for len(s) > 0 && '0' <= s[0] && s[0] <= '9' {
+			s = s[1:]
+		}
 	}
-	return buf
+
+	// Make sure we are at the end.
+	return s == ""
 }
 
 var hex = "0123456789abcdef"
@@ -0,0 +1,75 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestEscaping(t *testing.T) {
+	var keys []string
+	for c := 0; c < 0x20; c++ {
+		keys = append(keys, fmt.Sprintf(`\u%04x`, c))
+	}
+	keys = append(keys, `\u007f`, `\u2028`, `\u2029`, "\u2028", "\u2029", `\ud800`, `\udfff`, `\udc00\ud800`,
+		`😀`, `\/`, `\"`, `\\`, `\b\f\n\r\t`, "\x7f", `<&>`)
+	fn := func(key, value string) (string, bool) { return value + key, true }
+	for _, k := range keys {
+		input := fmt.Sprintf(`{"%[1]s":"%[1]s","a":["%[1]s"]}`, k)
+		var want map[string]interface{}
+		if err := json.Unmarshal([]byte(input), &want); err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		dst, err := sanitize.Message(nil, []byte(input), fn)
+		if err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		buf := new(bytes.Buffer)
+		if err := sanitize.StreamIndent(buf, strings.NewReader(input), fn, "", "\t"); err != nil {
+			t.Fatalf("%s: %v", k, err)
+		}
+		for _, out := range [][]byte{dst, buf.Bytes()} {
+			if !json.Valid(out) {
+				t.Fatalf("%s: invalid output %q", k, out)
+			}
+			// DEL is valid in json strings as is, encoding/json
+			// doesn't escape it either
+			if bytes.ContainsAny(out, "\u2028\u2029") {
+				t.Errorf("%s: unescaped characters in output %q", k, out)
+			}
+			for _, c := range out {
+				if c < 0x20 && c != '\n' && c != '\t' {
+					t.Errorf("%s: unescaped control character in output %q", k, out)
+					break
+				}
+			}
+			var got map[string]interface{}
+			if err := json.Unmarshal(out, &got); err != nil {
+				t.Fatalf("%s: %v", k, err)
+			}
+			for key, val := range want {
+				if s, ok := val.(string); ok && got[key] != s+key {
+					t.Errorf("%s: got value %q, want %q", k, got[key], s+key)
+				}
+			}
+			if fmt.Sprint(got["a"]) != fmt.Sprint(want["a"]) {
+				t.Errorf("%s: got array %q, want %q", k, got["a"], want["a"])
+			}
+		}
+	}
+}
+
+func TestEscapingInvalidReplacement(t *testing.T) {
+	fn := func(key, value string) (string, bool) { return "\xff\xfe\x00\x7f\u2028\xed\xa0\x80", true }
+	dst, err := sanitize.Message(nil, []byte(`{"a":"b"}`), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"a":"\ufffd\ufffd\u0000` + "\x7f" + `\u2028\ufffd\ufffd\ufffd"}`; string(dst) != want {
+		t.Fatalf("got %s, want %s", dst, want)
+	}
+}
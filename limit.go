@@ -0,0 +1,38 @@
+package sanitize
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+)
+
+// ErrOutputTooLarge is returned when sanitized output exceeds
+// Options.MaxOutputBytes.
+var ErrOutputTooLarge = errors.New("sanitize: output too large")
+
+// limitWriter holds output of the token being processed until it is
+// committed, so output never ends in the middle of a token and never exceeds
+// max bytes
+type limitWriter struct {
+	w   writer
+	buf bytes.Buffer // output of the current token
+	n   int64        // number of bytes committed to w
+	max int64
+}
+
+func (l *limitWriter) Write(p []byte) (int, error)       { return l.buf.Write(p) }
+func (l *limitWriter) WriteByte(c byte) error            { return l.buf.WriteByte(c) }
+func (l *limitWriter) WriteString(s string) (int, error) { return l.buf.WriteString(s) }
+
+// commit writes output of the current token to w, it fails if that would
+// exceed the limit; output of the token is discarded then
+func (l *limitWriter) commit() error {
+	if l.n+int64(l.buf.Len()) > l.max {
+		l.buf.Reset()
+		return fmt.Errorf("%w: more than %d bytes", ErrOutputTooLarge, l.max)
+	}
+	l.n += int64(l.buf.Len())
+	l.w.Write(l.buf.Bytes())
+	l.buf.Reset()
+	return nil
+}
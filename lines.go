@@ -0,0 +1,58 @@
+package sanitize
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// StreamLines sanitizes newline-delimited json read from r writing result to
+// w. Each line is processed as an independent json payload, blank lines are
+// skipped. Each sanitized record is written as a single line and flushed to w
+// right away, so record boundaries are visible to the consumer as soon as
+// possible. Line holding several json values, like {"a":1} {"b":2}, is still
+// written as a single line, with values separated by spaces.
+//
+// If some record cannot be processed, StreamLines stops and returns an error
+// prefixed with the line number of this record, which wraps the underlying
+// error.
+func StreamLines(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	br := bufio.NewReader(r)
+	bw := bufio.NewWriter(w)
+	var line, out []byte
+	for n := 1; ; n++ {
+		var err error
+		line = line[:0]
+		for {
+			var b []byte
+			b, err = br.ReadSlice('\n')
+			line = append(line, b...)
+			if err != bufio.ErrBufferFull {
+				break
+			}
+		}
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(bytes.TrimSpace(line)) != 0 {
+			var err error
+			s := newState(fn.field, nil)
+			s.inline = true
+			if out, err = message(out, line, s); err != nil {
+				return fmt.Errorf("line %d: %w", n, err)
+			}
+			bw.Write(out)
+			bw.WriteByte('\n')
+			if err := bw.Flush(); err != nil {
+				return err
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+	}
+}
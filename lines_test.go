@@ -0,0 +1,50 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamLines(t *testing.T) {
+	input := "\n" + input + "\n  \n" + `{"Msg":"Bye"}` + "\r\n" + `{"a":"b"}`
+	want := want + "\n" + `{"Msg":"********"}` + "\n" + `{"a":"********"}` + "\n"
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamLines(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStreamLinesSeveralValues(t *testing.T) {
+	const input = `{"Msg":"a"} {"Msg":"b"}{"c":1}` + "\n" + `[] 1` + "\n"
+	const want = `{"Msg":"********"} {"Msg":"********"} {"c":1}` + "\n" + `[] 1` + "\n"
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamLines(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStreamLinesError(t *testing.T) {
+	input := `{"Msg":"Hi"}` + "\n\n" + `{"Msg":` + "\n" + `{"Msg":"Bye"}`
+	buf := new(bytes.Buffer)
+	err := sanitize.StreamLines(buf, strings.NewReader(input), fn)
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	if !strings.HasPrefix(err.Error(), "line 3: ") || !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := buf.String(), `{"Msg":"********"}`+"\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
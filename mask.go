@@ -0,0 +1,373 @@
+package sanitize
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ReplaceInValue returns FieldFunc that passes each value through fn,
+// substituting value with the result of fn if it differs from the original.
+// It can be used to redact only parts of values, like card numbers embedded
+// in free text.
+func ReplaceInValue(fn func(value string) string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if s := fn(value); s != value {
+			return s, true
+		}
+		return "", false
+	}
+}
+
+// MaskKeepLast returns function that replaces all but the last n characters
+// of its argument with asterisks. If value is not longer than n characters,
+// it is masked completely. Characters are counted as runes, so multi-byte
+// characters are never split. Negative n is treated as 0.
+func MaskKeepLast(n int) func(string) string {
+	if n < 0 {
+		n = 0
+	}
+	return func(s string) string {
+		rs := []rune(s)
+		k := len(rs) - n
+		if k <= 0 {
+			k = len(rs)
+		}
+		return strings.Repeat("*", k) + string(rs[k:])
+	}
+}
+
+// MaskKeepFirst returns function that replaces all but the first n
+// characters of its argument with asterisks. If value is not longer than n
+// characters, it is masked completely. Characters are counted as runes, so
+// multi-byte characters are never split. Negative n is treated as 0.
+func MaskKeepFirst(n int) func(string) string {
+	if n < 0 {
+		n = 0
+	}
+	return func(s string) string {
+		rs := []rune(s)
+		k := n
+		if k >= len(rs) {
+			k = 0
+		}
+		return string(rs[:k]) + strings.Repeat("*", len(rs)-k)
+	}
+}
+
+// HashReplacer returns FieldFunc that substitutes every value with the
+// hex-encoded SHA-256 digest of salt followed by the value. The same value is
+// always replaced with the same digest given the same salt, which keeps
+// sanitized data joinable, while fixed digest size hides value length.
+func HashReplacer(salt []byte) FieldFunc {
+	return func(_, value string) (string, bool) {
+		h := sha256.New()
+		h.Write(salt)
+		io.WriteString(h, value)
+		return fmt.Sprintf("%x", h.Sum(nil)), true
+	}
+}
+
+// HashKeys returns KeyFunc that substitutes object keys matching pattern with
+// their digest computed the same way HashReplacer does for values, so the
+// same key is always replaced with the same digest given the same salt, both
+// within and across documents. Use it to pseudonymize identifiers used as
+// keys, like {"user-8675309":{...}}, keeping payload structure joinable.
+// Pattern is not anchored, use ^ and $ to match the whole key. Keys not
+// matching pattern are kept as is.
+func HashKeys(salt []byte, pattern *regexp.Regexp) KeyFunc {
+	hash := HashReplacer(salt)
+	return func(key string) (string, bool) {
+		if !pattern.MatchString(key) {
+			return "", false
+		}
+		return hash("", key)
+	}
+}
+
+// AllStrings returns FieldFunc that substitutes every string value with mask.
+// Like any FieldFunc, it only applies to object members, string elements of
+// arrays are kept as is.
+func AllStrings(mask string) FieldFunc {
+	return func(_, _ string) (string, bool) { return mask, true }
+}
+
+// MaskAllMessage returns the same result as Message called with
+// AllStrings(mask), but faster: it does not decode string values it masks,
+// and writes precomputed quoted mask in their place.
+func MaskAllMessage(dst, src []byte, mask string) ([]byte, error) {
+	z := getSanitizer()
+	defer putSanitizer(z)
+	if out, ok := maskAll(dst, src, mask, &z.s.sc); ok {
+		return out, nil
+	}
+	// let the generic implementation report the error
+	return Message(dst, src, AllStrings(mask))
+}
+
+// maskAll implements MaskAllMessage for a valid payload, it reports false if
+// payload is malformed
+func maskAll(dst, src []byte, mask string, sc *scanner) ([]byte, bool) {
+	if len(dst) > 0 {
+		dst = dst[:0]
+	}
+	src = bytes.TrimPrefix(src, bom)
+	buf := bytes.NewBuffer(dst)
+	quoted := new(bytes.Buffer)
+	quoted.WriteByte('"')
+	writeEscapedString(quoted, mask, true)
+	quoted.WriteByte('"')
+	sc.reset(src)
+	sc.discard = true
+	// first is whether the next member or element is the first one in its
+	// object or array; afterKey is whether the next value belongs to the
+	// just written key
+	first, afterKey := true, false
+	for {
+		t, err := sc.next()
+		if err == io.EOF {
+			return buf.Bytes(), len(sc.stack) == 0
+		}
+		if err != nil {
+			return nil, false
+		}
+		if t.delim == '}' || t.delim == ']' {
+			buf.WriteByte(t.delim)
+			first = false
+			continue
+		}
+		if t.delim == 0 && t.kind == String && sc.state == scanObjectColon {
+			if !first {
+				buf.WriteByte(comma)
+			}
+			sc.writeString(buf)
+			buf.WriteByte(colon)
+			first, afterKey = false, true
+			continue
+		}
+		depth := len(sc.stack) // of the object or array holding the value
+		if t.delim != 0 {
+			depth--
+		}
+		if !afterKey && depth > 0 && !first {
+			buf.WriteByte(comma)
+		}
+		if depth == 0 && buf.Len() > 0 {
+			buf.WriteByte('\n') // separate consecutive top-level values
+		}
+		masked := afterKey || depth == 0
+		first, afterKey = false, false
+		switch {
+		case t.delim != 0:
+			buf.WriteByte(t.delim)
+			first = true
+		case t.kind == String && masked:
+			buf.Write(quoted.Bytes())
+		case t.kind == String:
+			sc.writeString(buf)
+		case t.kind == Number:
+			buf.Write(src[sc.start:sc.pos])
+		default:
+			buf.WriteString(t.v)
+		}
+	}
+}
+
+// writeString writes the last string read by discarding scanner to buf, the
+// same way Message does
+func (sc *scanner) writeString(buf *bytes.Buffer) {
+	if raw := rawString(sc.src[:sc.pos]); raw != nil {
+		buf.Write(raw)
+		return
+	}
+	sc.discard = false
+	sc.pos = sc.start
+	s, _ := sc.string()
+	sc.discard = true
+	buf.WriteByte('"')
+	writeEscapedString(buf, s, true)
+	buf.WriteByte('"')
+}
+
+// rawString returns the string token at the tail of b, quotes included, if
+// its json text is exactly what writeString would produce, so it can be copied
+// without decoding and re-escaping: it holds no escape sequences, non-ASCII
+// characters, or characters writeString escapes. Otherwise it returns nil.
+func rawString(b []byte) []byte {
+	if len(b) < 2 || b[len(b)-1] != '"' {
+		return nil
+	}
+	for i := len(b) - 2; i >= 0; i-- {
+		switch c := b[i]; {
+		case c == '"':
+			if i > 0 && b[i-1] == '\\' {
+				return nil // escaped quote
+			}
+			return b[i:]
+		case c >= utf8.RuneSelf || !htmlSafeSet[c]:
+			return nil
+		}
+	}
+	return nil
+}
+
+// FromMap returns FieldFunc that substitutes values of attributes with keys
+// present in m with the replacement m holds for the key, like
+// {"password":"***","ssn":"XXX-XX-XXXX"}. Attributes with other keys are
+// kept as is. The map is used as is and must not be modified while FieldFunc
+// is in use.
+func FromMap(m map[string]string) FieldFunc {
+	return func(key, _ string) (string, bool) {
+		val, ok := m[key]
+		return val, ok
+	}
+}
+
+// FromMapDefault is a variant of FromMap that, if applyDefault is true,
+// substitutes values of attributes with keys absent from m with def. Exact
+// key always takes precedence over the default; "*" has no special meaning as
+// a key of m. With applyDefault set to false FromMapDefault works the same
+// way FromMap does.
+func FromMapDefault(m map[string]string, def string, applyDefault bool) FieldFunc {
+	return func(key, _ string) (string, bool) {
+		if val, ok := m[key]; ok {
+			return val, true
+		}
+		return def, applyDefault
+	}
+}
+
+// KeysFold returns FieldFunc that substitutes with mask values of attributes
+// with any of keys, comparing keys case-insensitively, so "password" matches
+// "Password" and "PASSWORD" as well.
+//
+// Keys are compared under full Unicode simple case folding, the same way
+// strings.EqualFold does, not just ASCII: "straße" matches "STRAẞE", and
+// "k" matches the Kelvin sign "\u212a". Special casing that changes string
+// length, like "ß" to "SS", is not applied.
+func KeysFold(mask string, keys ...string) FieldFunc {
+	set := make(map[string]struct{}, len(keys))
+	for _, k := range keys {
+		set[foldString(k)] = struct{}{}
+	}
+	return func(key, _ string) (string, bool) {
+		if _, ok := set[foldString(key)]; ok {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+// foldString returns s with each rune replaced by the smallest rune of its
+// case folding orbit, so strings equal under strings.EqualFold have the same
+// result
+func foldString(s string) string {
+	return strings.Map(func(r rune) rune {
+		if r < utf8.RuneSelf {
+			// upper case ASCII letter is the smallest rune of its
+			// orbit, even for 'k' and 's' which also fold with
+			// non-ASCII runes
+			if 'a' <= r && r <= 'z' {
+				r -= 'a' - 'A'
+			}
+			return r
+		}
+		min := r
+		for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+			if f < min {
+				min = f
+			}
+		}
+		return min
+	}, s)
+}
+
+// MaskSameLength returns FieldFunc that substitutes every value with ch
+// repeated as many times as there are runes in the value, so masked value
+// keeps its visual length. Empty values stay empty.
+func MaskSameLength(ch rune) FieldFunc {
+	mask := string(ch)
+	return func(_, value string) (string, bool) {
+		return strings.Repeat(mask, utf8.RuneCountInString(value)), true
+	}
+}
+
+// MaskPreserveLength returns FieldFunc that substitutes every value with ch
+// repeated once for each rune of the value, so masked value keeps its length.
+// It is the same as MaskSameLength, named to pair with MaskPreserveInitial.
+func MaskPreserveLength(ch rune) FieldFunc { return MaskSameLength(ch) }
+
+// MaskPreserveInitial returns FieldFunc that substitutes every value with its
+// first character followed by an asterisk for each of the rest, so "John"
+// becomes "J***", keeping both the initial and the length in runes. Like
+// MaskKeepFirst(1), which it is a shorthand for, it masks single-character
+// values completely, as keeping the initial would reveal them. Empty values
+// stay empty.
+func MaskPreserveInitial() FieldFunc { return ReplaceInValue(MaskKeepFirst(1)) }
+
+// SequentialMask returns FieldFunc that substitutes every value with prefix
+// followed by a sequence number starting with 1, in the order values appear in
+// the document: SequentialMask("REDACTED-") replaces values with "REDACTED-1",
+// "REDACTED-2" and so on. This allows matching redacted values with their
+// originals stored separately. The second returned function reports how many
+// values were substituted so far.
+//
+// Returned functions share the counter, which is not reset between calls, and
+// must not be used concurrently: call SequentialMask for each goroutine or
+// each document that needs its own numbering.
+func SequentialMask(prefix string) (FieldFunc, func() int) {
+	var n int
+	fn := func(_, _ string) (string, bool) {
+		n++
+		return prefix + strconv.Itoa(n), true
+	}
+	return fn, func() int { return n }
+}
+
+// RedactAfterFirst returns FieldFunc that substitutes with mask values of
+// attributes with keys present in keys, except for the first occurrence of
+// each key, which is kept as is. This suits payloads where the first value
+// under a key is a header label, and the following ones are data.
+//
+// Returned function counts occurrences across all calls and is never reset:
+// call RedactAfterFirst for each document, and don't use the same function
+// concurrently.
+func RedactAfterFirst(keys map[string]struct{}, mask string) FieldFunc {
+	seen := make(map[string]struct{})
+	return func(key, _ string) (string, bool) {
+		if _, ok := keys[key]; !ok {
+			return "", false
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			return "", false
+		}
+		return mask, true
+	}
+}
+
+// TruncateValues returns FieldFunc that substitutes values longer than max
+// runes with their first max runes followed by suffix, like "…(truncated)".
+// Values of max runes or shorter are kept as is. Use it as the last of Chain
+// functions to only truncate values not redacted otherwise.
+func TruncateValues(max int, suffix string) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if len(value) <= max {
+			return "", false
+		}
+		n := 0
+		for i := range value {
+			if n == max {
+				return value[:i] + suffix, true
+			}
+			n++
+		}
+		return "", false
+	}
+}
@@ -0,0 +1,342 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestReplaceInValue(t *testing.T) {
+	const input = `{"message":"карта 4242 4242 4242 4242, \"код\"\t\u2028😀","n":"1234"}`
+	const want = `{"message":"карта ****, \"код\"\t\u2028😀","n":"1234"}`
+	re := regexp.MustCompile(`\d{4}( ?\d{4}){3}`)
+	fn := sanitize.ReplaceInValue(func(s string) string { return re.ReplaceAllString(s, "****") })
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMaskKeep(t *testing.T) {
+	for _, tc := range []struct {
+		fn       func(string) string
+		in, want string
+	}{
+		{sanitize.MaskKeepLast(4), "4242424242424242", "************4242"},
+		{sanitize.MaskKeepLast(4), "пароль😀", "***оль😀"},
+		{sanitize.MaskKeepLast(4), "кот", "***"},
+		{sanitize.MaskKeepLast(4), "кошк", "****"},
+		{sanitize.MaskKeepLast(4), "", ""},
+		{sanitize.MaskKeepFirst(1), "john", "j***"},
+		{sanitize.MaskKeepFirst(2), "😀ёжик", "😀ё***"},
+		{sanitize.MaskKeepFirst(2), "ёж", "**"},
+		{sanitize.MaskKeepFirst(0), "abc", "***"},
+		{sanitize.MaskKeepFirst(-1), "abc", "***"},
+		{sanitize.MaskKeepLast(0), "abc", "***"},
+		{sanitize.MaskKeepLast(-5), "abc", "***"},
+	} {
+		if got := tc.fn(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+}
+
+func ExampleMaskKeepLast() {
+	msg := `{"card":"4242 4242 4242 4242","email":"юзер@example.com"}`
+	keepLast := sanitize.MaskKeepLast(4)
+	keepFirst := sanitize.MaskKeepFirst(1)
+	fn := func(key, value string) (string, bool) {
+		switch key {
+		case "card":
+			return keepLast(value), true
+		case "email":
+			if i := strings.IndexByte(value, '@'); i > 0 {
+				return keepFirst(value[:i]) + value[i:], true
+			}
+		}
+		return "", false
+	}
+	out, err := sanitize.Message(nil, []byte(msg), fn)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(string(out))
+	// Output:
+	// {"card":"***************4242","email":"ю***@example.com"}
+}
+
+func TestHashReplacer(t *testing.T) {
+	const input = `{"a":"secret","b":"secret","c":"other","n":1}`
+	// echo -n 'saltsecret' | sha256sum
+	const h = "bede90386d450cea8b77b822f8887065e4e5abf132c2f9dccfcc7fbd4cba5e35"
+	fn := sanitize.HashReplacer([]byte("salt"))
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var out map[string]interface{}
+	if err := json.Unmarshal(dst, &out); err != nil {
+		t.Fatal(err)
+	}
+	if out["a"] != h || out["b"] != h {
+		t.Fatalf("unexpected digests: %s", dst)
+	}
+	if c := out["c"].(string); c == h || len(c) != len(h) {
+		t.Fatalf("unexpected digest of other value: %s", dst)
+	}
+}
+
+func TestHashKeys(t *testing.T) {
+	const input = `{"user-1":{"id":"x","user-2":"y"},"name":"z","list":[{"user-1":1}],"x-user-1":2}`
+	keys := sanitize.HashKeys([]byte("salt"), regexp.MustCompile(`^user-\d+$`))
+	opts := &sanitize.Options{Keys: keys}
+	noop := func(sanitize.Field) (string, bool) { return "", false }
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), noop, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h1, _ := sanitize.HashReplacer([]byte("salt"))("", "user-1")
+	h2, _ := sanitize.HashReplacer([]byte("salt"))("", "user-2")
+	want := `{"` + h1 + `":{"id":"x","` + h2 + `":"y"},"name":"z","list":[{"` + h1 + `":1}],"x-user-1":2}`
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	dst2, err := sanitize.MessageWithOptions(nil, []byte(input), noop, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(dst, dst2) {
+		t.Fatalf("second run differs:\n%s\n%s", dst, dst2)
+	}
+	other := sanitize.HashKeys([]byte("other"), regexp.MustCompile(`^user-`))
+	if k, _ := other("user-1"); k == h1 {
+		t.Fatal("different salt produced the same key")
+	}
+}
+
+func TestAllStrings(t *testing.T) {
+	const want = `{"Msg":"*","Obj":{"a":1,"c":"*","b":null},"Arr":["a","b","c"],"Null":null,"Num":1.234}`
+	dst, err := sanitize.Message(nil, []byte(input), sanitize.AllStrings("*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestKeysFold(t *testing.T) {
+	const input = `{"Password":"a","password":"b","PASSWORD":"c","passwords":"d","\u212aey":"e",` +
+		`"STRASSE":"f","Stra\u00dfe":"g","\u00c9t\u00e9":"h"}`
+	const want = `{"Password":"*","password":"*","PASSWORD":"*","passwords":"d","\u212aey":"*",` +
+		`"STRASSE":"f","Stra\u00dfe":"*","\u00c9t\u00e9":"*"}`
+	fn := sanitize.KeysFold("*", "password", "key", "STRA\u1e9eE", "\u00e9T\u00c9")
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got, wantv map[string]string
+	if err := json.Unmarshal(dst, &got); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal([]byte(want), &wantv); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantv) {
+		t.Fatalf("got:\n%v\nwant:\n%v", got, wantv)
+	}
+}
+
+func TestMaskSameLength(t *testing.T) {
+	const input = `{"a":"secret","b":"пароль","c":"😀x","d":"","e":"été","n":1}`
+	const want = `{"a":"######","b":"######","c":"##","d":"","e":"###","n":1}`
+	dst, err := sanitize.Message(nil, []byte(input), sanitize.MaskSameLength('#'))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	fn := sanitize.MaskSameLength('•')
+	if got, _ := fn("", "ёжик"); got != "••••" {
+		t.Fatalf("got %q, want 4 runes", got)
+	}
+}
+
+func TestMaskPreserve(t *testing.T) {
+	for _, tc := range []struct {
+		fn          sanitize.FieldFunc
+		value, want string
+	}{
+		{sanitize.MaskPreserveInitial(), "John", "J***"},
+		{sanitize.MaskPreserveInitial(), "\u00c9mile", "\u00c9****"},
+		{sanitize.MaskPreserveInitial(), "\U0001f600ab", "\U0001f600**"},
+		{sanitize.MaskPreserveInitial(), "J", "*"},
+		{sanitize.MaskPreserveInitial(), "\u00c9", "*"},
+		{sanitize.MaskPreserveInitial(), "", ""},
+		{sanitize.MaskPreserveLength('#'), "\u0436\u0438\u0437\u043d\u044c", "#####"},
+		{sanitize.MaskPreserveLength('#'), "", ""},
+	} {
+		got, ok := tc.fn("name", tc.value)
+		if !ok {
+			got = tc.value
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.value, got, tc.want)
+		}
+	}
+	dst, err := sanitize.Message(nil, []byte(`{"first":"John","last":""}`), sanitize.MaskPreserveInitial())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), `{"first":"J***","last":""}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestSequentialMask(t *testing.T) {
+	const input = `{"a":"x","b":{"c":"y","d":[{"e":"z"},"w"],"f":1},"g":"x"}`
+	const want = `{"a":"R-1","b":{"c":"R-2","d":[{"e":"R-3"},"w"],"f":1},"g":"R-4"}`
+	fn, count := sanitize.SequentialMask("R-")
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if n := count(); n != 4 {
+		t.Fatalf("got count %d, want 4", n)
+	}
+}
+
+func TestRedactAfterFirst(t *testing.T) {
+	const input = `[{"name":"Name","id":"ID"},{"name":"bob","id":"1"},{"name":"amy","id":"2","x":{"name":"eve"}}]`
+	const want = `[{"name":"Name","id":"ID"},{"name":"*","id":"1"},{"name":"*","id":"2","x":{"name":"*"}}]`
+	fn := sanitize.RedactAfterFirst(map[string]struct{}{"name": {}}, "*")
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestTruncateValues(t *testing.T) {
+	const suffix = "…(truncated)"
+	trunc := sanitize.TruncateValues(4, suffix)
+	for _, tc := range []struct {
+		in, want string
+		ok       bool
+	}{
+		{"", "", false},
+		{"abc", "", false},
+		{"abcd", "", false},
+		{"abcde", "abcd" + suffix, true},
+		{"ёжик", "", false},
+		{"ёжики", "ёжик" + suffix, true},
+		{"😀😀😀😀😀😀", "😀😀😀😀" + suffix, true},
+	} {
+		got, ok := trunc("k", tc.in)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%q: got (%q, %v), want (%q, %v)", tc.in, got, ok, tc.want, tc.ok)
+		}
+	}
+	const input = `{"Msg":"Hello, world","trace":"panic: runtime error","id":"abc"}`
+	const want = `{"Msg":"********","trace":"panic` + suffix + `","id":"abc"}`
+	dst, err := sanitize.Message(nil, []byte(input), sanitize.Chain(fn, sanitize.TruncateValues(5, suffix)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMaskAllMessage(t *testing.T) {
+	for _, input := range append(testCorpus(), input, "\xef\xbb\xbf"+input,
+		`{"key":"a\"b","<":["<",{"x":"y"}],"é":"é"} "top" [{"a":[1,"b"]},2.50e1]`,
+		`{"a\/b":["http:\/\/x\/"],"\/":"\/"}`) {
+		want, err1 := sanitize.Message(nil, []byte(input), sanitize.AllStrings("<*>"))
+		got, err2 := sanitize.MaskAllMessage(nil, []byte(input), "<*>")
+		if fmt.Sprint(err1) != fmt.Sprint(err2) {
+			t.Fatalf("%q: Message error: %v, MaskAllMessage error: %v", input, err1, err2)
+		}
+		if string(got) != string(want) {
+			t.Fatalf("%q: got:\n%s\nwant:\n%s", input, got, want)
+		}
+	}
+}
+
+func BenchmarkMaskAllMessage(b *testing.B) {
+	src := []byte(keyHeavyInput)
+	dst := make([]byte, len(src))
+	b.Run("AllStrings", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(src)))
+		var err error
+		for i := 0; i < b.N; i++ {
+			if dst, err = sanitize.Message(dst, src, sanitize.AllStrings("***")); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("MaskAllMessage", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(src)))
+		var err error
+		for i := 0; i < b.N; i++ {
+			if dst, err = sanitize.MaskAllMessage(dst, src, "***"); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func TestFromMap(t *testing.T) {
+	const input = `{"password":"p","ssn":"123-45-6789","email":"a@b.c","name":"n","x":{"password":"q"},"empty":"e"}`
+	const want = `{"password":"***","ssn":"XXX-XX-XXXX","email":"hidden@example.com","name":"n","x":{"password":"***"},"empty":""}`
+	fn := sanitize.FromMap(map[string]string{
+		"password": "***",
+		"ssn":      "XXX-XX-XXXX",
+		"email":    "hidden@example.com",
+		"empty":    "",
+	})
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFromMapDefault(t *testing.T) {
+	const input = `{"password":"p","name":"n","*":"star","n":1}`
+	m := map[string]string{"password": "***", "*": "STAR"}
+	for _, tc := range []struct {
+		apply bool
+		want  string
+	}{
+		{true, `{"password":"***","name":"-","*":"STAR","n":1}`},
+		{false, `{"password":"***","name":"n","*":"STAR","n":1}`},
+	} {
+		dst, err := sanitize.Message(nil, []byte(input), sanitize.FromMapDefault(m, "-", tc.apply))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("applyDefault %v got:\n%s\nwant:\n%s", tc.apply, got, tc.want)
+		}
+	}
+}
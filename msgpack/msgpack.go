@@ -0,0 +1,311 @@
+// Package msgpack sanitizes MessagePack input into json output, applying
+// sanitize.FieldFunc to string values the same way package sanitize does for
+// json input.
+//
+// Package implements its own MessagePack decoder, so neither it nor the core
+// package depend on third-party modules.
+package msgpack
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+
+	"github.com/artyom/sanitize"
+)
+
+// StreamMsgpack reads MessagePack values from r and writes them to w as
+// json, substituting string values with fn the same way sanitize.Stream does:
+// fn is called on string values of map entries with string keys, and on
+// top-level string values with an empty key; string elements of arrays are
+// written as is.
+//
+// MessagePack types map to json as follows: nil, booleans, integers and
+// floats to their json counterparts, strings to json strings, binary data to
+// base64-encoded json strings, arrays to json arrays and maps to json
+// objects. Map keys must be strings or integers, the latter written as their
+// decimal form. Extension types and NaN or infinite floats have no json
+// representation and are reported as errors.
+//
+// Consecutive top-level values are written separated by newlines, the same
+// as sanitize.Stream does for multiple json values.
+func StreamMsgpack(w io.Writer, r io.Reader, fn sanitize.FieldFunc) error {
+	if fn == nil {
+		return errors.New("msgpack: nil FieldFunc")
+	}
+	d := &decoder{
+		r:  bufio.NewReader(r),
+		w:  bufio.NewWriter(w),
+		fn: fn,
+	}
+	for n := 0; ; n++ {
+		if _, err := d.r.Peek(1); err == io.EOF {
+			break
+		}
+		if n > 0 {
+			d.w.WriteByte('\n')
+		}
+		if err := d.value("", true, 0); err != nil {
+			d.w.Flush()
+			return err
+		}
+	}
+	return d.w.Flush()
+}
+
+// maxDepth limits nesting of arrays and maps
+const maxDepth = 10000
+
+type decoder struct {
+	r   *bufio.Reader
+	w   *bufio.Writer
+	fn  sanitize.FieldFunc
+	buf bytes.Buffer
+}
+
+// value decodes single value and writes it as json; fn is only called on
+// strings if member is true
+func (d *decoder) value(key string, member bool, depth int) error {
+	if depth > maxDepth {
+		return errors.New("msgpack: exceeded max depth")
+	}
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return unexpectedEOF(err)
+	}
+	switch {
+	case c <= 0x7f:
+		d.w.WriteString(strconv.Itoa(int(c)))
+		return nil
+	case c >= 0xe0:
+		d.w.WriteString(strconv.Itoa(int(int8(c))))
+		return nil
+	case c&0xf0 == 0x80:
+		return d.object(int(c&0x0f), depth)
+	case c&0xf0 == 0x90:
+		return d.array(int(c&0x0f), depth)
+	case c&0xe0 == 0xa0:
+		return d.str(int(c&0x1f), key, member)
+	}
+	switch c {
+	case 0xc0:
+		d.w.WriteString("null")
+	case 0xc2:
+		d.w.WriteString("false")
+	case 0xc3:
+		d.w.WriteString("true")
+	case 0xc4, 0xc5, 0xc6:
+		n, err := d.length(1 << (c - 0xc4))
+		if err != nil {
+			return err
+		}
+		if err := d.read(n); err != nil {
+			return err
+		}
+		d.w.WriteByte('"')
+		d.w.WriteString(base64.StdEncoding.EncodeToString(d.buf.Bytes()))
+		d.w.WriteByte('"')
+	case 0xca, 0xcb:
+		var f float64
+		if c == 0xca {
+			u, err := d.uint(4)
+			if err != nil {
+				return err
+			}
+			f = float64(math.Float32frombits(uint32(u)))
+		} else {
+			u, err := d.uint(8)
+			if err != nil {
+				return err
+			}
+			f = math.Float64frombits(u)
+		}
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return fmt.Errorf("msgpack: unsupported float value %v", f)
+		}
+		b, _ := json.Marshal(f)
+		d.w.Write(b)
+	case 0xcc, 0xcd, 0xce, 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return err
+		}
+		d.w.WriteString(strconv.FormatUint(u, 10))
+	case 0xd0, 0xd1, 0xd2, 0xd3:
+		i, err := d.int(1 << (c - 0xd0))
+		if err != nil {
+			return err
+		}
+		d.w.WriteString(strconv.FormatInt(i, 10))
+	case 0xd9, 0xda, 0xdb:
+		n, err := d.length(1 << (c - 0xd9))
+		if err != nil {
+			return err
+		}
+		return d.str(n, key, member)
+	case 0xdc, 0xdd:
+		n, err := d.length(2 << (c - 0xdc))
+		if err != nil {
+			return err
+		}
+		return d.array(n, depth)
+	case 0xde, 0xdf:
+		n, err := d.length(2 << (c - 0xde))
+		if err != nil {
+			return err
+		}
+		return d.object(n, depth)
+	default:
+		return fmt.Errorf("msgpack: unsupported type 0x%02x", c)
+	}
+	return nil
+}
+
+func (d *decoder) object(n, depth int) error {
+	d.w.WriteByte('{')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			d.w.WriteByte(',')
+		}
+		key, err := d.key()
+		if err != nil {
+			return err
+		}
+		writeString(d.w, key)
+		d.w.WriteByte(':')
+		if err := d.value(key, true, depth+1); err != nil {
+			return err
+		}
+	}
+	d.w.WriteByte('}')
+	return nil
+}
+
+func (d *decoder) array(n, depth int) error {
+	d.w.WriteByte('[')
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			d.w.WriteByte(',')
+		}
+		if err := d.value("", false, depth+1); err != nil {
+			return err
+		}
+	}
+	d.w.WriteByte(']')
+	return nil
+}
+
+// key decodes map key, which must be a string or an integer
+func (d *decoder) key() (string, error) {
+	c, err := d.r.ReadByte()
+	if err != nil {
+		return "", unexpectedEOF(err)
+	}
+	var n int
+	switch {
+	case c <= 0x7f:
+		return strconv.Itoa(int(c)), nil
+	case c >= 0xe0:
+		return strconv.Itoa(int(int8(c))), nil
+	case c&0xe0 == 0xa0:
+		n = int(c & 0x1f)
+	case c == 0xd9, c == 0xda, c == 0xdb:
+		if n, err = d.length(1 << (c - 0xd9)); err != nil {
+			return "", err
+		}
+	case c >= 0xcc && c <= 0xcf:
+		u, err := d.uint(1 << (c - 0xcc))
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatUint(u, 10), nil
+	case c >= 0xd0 && c <= 0xd3:
+		i, err := d.int(1 << (c - 0xd0))
+		if err != nil {
+			return "", err
+		}
+		return strconv.FormatInt(i, 10), nil
+	default:
+		return "", fmt.Errorf("msgpack: unsupported map key type 0x%02x", c)
+	}
+	if err := d.read(n); err != nil {
+		return "", err
+	}
+	return d.buf.String(), nil
+}
+
+// str reads string of n bytes and writes it, substituted with fn if member
+// is true
+func (d *decoder) str(n int, key string, member bool) error {
+	if err := d.read(n); err != nil {
+		return err
+	}
+	s := d.buf.String()
+	if member {
+		if v, ok := d.fn(key, s); ok {
+			s = v
+		}
+	}
+	writeString(d.w, s)
+	return nil
+}
+
+// read reads n bytes into d.buf; buffer grows as data is read, so bogus
+// lengths don't cause huge allocations
+func (d *decoder) read(n int) error {
+	d.buf.Reset()
+	if _, err := io.CopyN(&d.buf, d.r, int64(n)); err != nil {
+		return unexpectedEOF(err)
+	}
+	return nil
+}
+
+// length reads big-endian unsigned length of size bytes
+func (d *decoder) length(size int) (int, error) {
+	u, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	if u > math.MaxInt32 {
+		return 0, fmt.Errorf("msgpack: length %d is too large", u)
+	}
+	return int(u), nil
+}
+
+// uint reads big-endian unsigned integer of size bytes
+func (d *decoder) uint(size int) (uint64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(d.r, b[8-size:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return binary.BigEndian.Uint64(b[:]), nil
+}
+
+// int reads big-endian signed integer of size bytes
+func (d *decoder) int(size int) (int64, error) {
+	u, err := d.uint(size)
+	if err != nil {
+		return 0, err
+	}
+	shift := uint(64 - 8*size)
+	return int64(u<<shift) >> shift, nil
+}
+
+func writeString(w *bufio.Writer, s string) {
+	b, _ := json.Marshal(s)
+	w.Write(b)
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,103 @@
+package msgpack_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+	"github.com/artyom/sanitize/msgpack"
+)
+
+func TestStreamMsgpack(t *testing.T) {
+	var b bytes.Buffer
+	// {"Msg":"Hi","Obj":{"a":1,"c":"C","b":nil},"Arr":["a","b","c"],"Null":nil,"Num":1.234}
+	b.WriteByte(0x85)
+	fixstr(&b, "Msg")
+	fixstr(&b, "Hi")
+	fixstr(&b, "Obj")
+	b.WriteByte(0x83)
+	fixstr(&b, "a")
+	b.WriteByte(0x01)
+	fixstr(&b, "c")
+	fixstr(&b, "C")
+	fixstr(&b, "b")
+	b.WriteByte(0xc0)
+	fixstr(&b, "Arr")
+	b.WriteByte(0x93)
+	fixstr(&b, "a")
+	fixstr(&b, "b")
+	fixstr(&b, "c")
+	fixstr(&b, "Null")
+	b.WriteByte(0xc0)
+	fixstr(&b, "Num")
+	b.WriteByte(0xcb)
+	binary.Write(&b, binary.BigEndian, math.Float64bits(1.234))
+	// second top-level value:
+	// {"bin":[1,2],-1:-200,300:true,"c":<str8 "<x>">,"big":2^64-1,"f":false}
+	b.WriteByte(0x86)
+	fixstr(&b, "bin")
+	b.Write([]byte{0xc4, 2, 1, 2})
+	b.WriteByte(0xff)
+	b.Write([]byte{0xd1, 0xff, 0x38})
+	b.Write([]byte{0xcd, 0x01, 0x2c})
+	b.WriteByte(0xc3)
+	fixstr(&b, "c")
+	b.Write([]byte{0xd9, 3})
+	b.WriteString("<x>")
+	fixstr(&b, "big")
+	b.Write([]byte{0xcf, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff})
+	fixstr(&b, "f")
+	b.WriteByte(0xc2)
+
+	const want = `{"Msg":"********","Obj":{"a":1,"c":"********","b":null},"Arr":["a","b","c"],"Null":null,"Num":1.234}` + "\n" +
+		`{"bin":"AQI=","-1":-200,"300":true,"c":"\u003cx\u003e","big":18446744073709551615,"f":false}`
+	fn := func(key, val string) (string, bool) {
+		if key == "Msg" || val == "C" {
+			return sanitize.Mask, true
+		}
+		return "", false
+	}
+	out := new(strings.Builder)
+	if err := msgpack.StreamMsgpack(out, &b, fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := out.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestStreamMsgpackErrors(t *testing.T) {
+	fn := sanitize.AllStrings(sanitize.Mask)
+	nan := append([]byte{0xcb}, make([]byte, 8)...)
+	binary.BigEndian.PutUint64(nan[1:], math.Float64bits(math.NaN()))
+	for _, tc := range []struct {
+		name  string
+		input []byte
+	}{
+		{"truncated map", []byte{0x81, 0xa1, 'a'}},
+		{"truncated string", []byte{0xa3, 'a'}},
+		{"huge string", []byte{0xdb, 0x7f, 0xff, 0xff, 0xff, 'a'}},
+		{"extension", []byte{0xd4, 1, 0}},
+		{"array key", []byte{0x81, 0x90, 0xc0}},
+		{"nan", nan},
+	} {
+		err := msgpack.StreamMsgpack(ioutil.Discard, bytes.NewReader(tc.input), fn)
+		if err == nil {
+			t.Errorf("%s: got nil error", tc.name)
+		}
+	}
+	err := msgpack.StreamMsgpack(ioutil.Discard, bytes.NewReader([]byte{0x91, 0xa1}), fn)
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("got %v, want io.ErrUnexpectedEOF", err)
+	}
+}
+
+func fixstr(b *bytes.Buffer, s string) {
+	b.WriteByte(0xa0 | byte(len(s)))
+	b.WriteString(s)
+}
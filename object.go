@@ -0,0 +1,183 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// ObjectFunc is called on each json object of the payload with its members,
+// so it can make decisions depending on sibling members, like redacting
+// "value" only when "type" is "secret". Function returns members to write
+// in place of the object: it may modify obj and return it, or return a new
+// map. Members missing from the result are dropped, nil result is written as
+// an empty object. Each returned value must be a valid json.
+//
+// Objects are processed innermost first, so when ObjectFunc is called on an
+// object, objects nested in its members are already processed.
+//
+// Members present in the payload are written in their original order, new
+// members are written after them sorted by key. If payload object has
+// duplicate keys, only the last member for each key is passed to ObjectFunc.
+type ObjectFunc func(obj map[string]json.RawMessage) map[string]json.RawMessage
+
+// StreamObject is a variant of Stream that calls fn on each json object of
+// the payload. Each object is read into memory in full before fn is called
+// on it, but elements of the top-level array are processed and written one
+// at a time, so payloads like large arrays of records are not loaded as a
+// whole.
+func StreamObject(w io.Writer, r io.Reader, fn ObjectFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	return stream(w, r, &state{objectFn: fn})
+}
+
+// MessageObject is a variant of Message that calls fn on each json object of
+// the payload.
+func MessageObject(dst, src []byte, fn ObjectFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	return message(dst, src, &state{objectFn: fn})
+}
+
+// runObjects processes payload calling s.objectFn on each object
+func (s *state) runObjects() error {
+	for {
+		t, err := s.tok.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return s.syntaxError(err)
+		}
+		if s.ntop > 0 {
+			s.w.WriteByte('\n')
+		}
+		if err := s.walkValue(s.w, t, 0, s.object); err != nil {
+			return err
+		}
+		s.ntop++
+	}
+}
+
+// nextObjectToken returns next token of the value being processed by
+// runObjects
+func (s *state) nextObjectToken() (token, error) {
+	t, err := s.tok.next()
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return t, s.syntaxError(err)
+	}
+	return t, nil
+}
+
+// walkValue writes value starting with token t nested in depth objects and
+// arrays to w in compact form. Once opening brace of an object is consumed,
+// the rest of the object is handed to object along with its depth, which
+// includes the object itself.
+func (s *state) walkValue(w writer, t token, depth int, object func(w writer, depth int) error) error {
+	if t.delim != 0 && depth >= maxNestingDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, maxNestingDepth)
+	}
+	switch t.delim {
+	case '[':
+		w.WriteByte('[')
+		for n := 0; ; n++ {
+			t, err := s.nextObjectToken()
+			if err != nil {
+				return err
+			}
+			if t.delim == ']' {
+				break
+			}
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			if err := s.walkValue(w, t, depth+1, object); err != nil {
+				return err
+			}
+		}
+		w.WriteByte(']')
+		return nil
+	case '{':
+		return object(w, depth+1)
+	}
+	if t.kind == String {
+		w.WriteByte('"')
+		writeEscapedString(w, t.v, true)
+		w.WriteByte('"')
+		return nil
+	}
+	w.WriteString(t.v)
+	return nil
+}
+
+// object reads members of the object which opening brace is already consumed,
+// and writes the object returned by s.objectFn to w. depth includes the object
+// itself.
+func (s *state) object(w writer, depth int) error {
+	var keys []string
+	seen := make(map[string]struct{})
+	obj := make(map[string]json.RawMessage)
+	for {
+		t, err := s.nextObjectToken()
+		if err != nil {
+			return err
+		}
+		if t.delim == '}' {
+			break
+		}
+		key := t.v
+		if t, err = s.nextObjectToken(); err != nil {
+			return err
+		}
+		buf := new(bytes.Buffer)
+		if err := s.walkValue(buf, t, depth, s.object); err != nil {
+			return err
+		}
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			keys = append(keys, key)
+		}
+		obj[key] = buf.Bytes()
+	}
+	obj = s.objectFn(obj)
+	var extra []string
+	for k := range obj {
+		if _, ok := seen[k]; !ok {
+			extra = append(extra, k)
+		}
+	}
+	sort.Strings(extra)
+	w.WriteByte('{')
+	n := 0
+	var val bytes.Buffer
+	for _, list := range [][]string{keys, extra} {
+		for _, k := range list {
+			v, ok := obj[k]
+			if !ok {
+				continue
+			}
+			val.Reset()
+			if err := json.Compact(&val, v); err != nil {
+				return fmt.Errorf("sanitize: invalid value of %q returned by ObjectFunc: %w", k, err)
+			}
+			if n > 0 {
+				w.WriteByte(',')
+			}
+			n++
+			w.WriteByte('"')
+			writeEscapedString(w, k, true)
+			w.WriteString(`":`)
+			w.Write(val.Bytes())
+		}
+	}
+	w.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,102 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestMessageObject(t *testing.T) {
+	const input = `[{"type":"secret","value":"s3cr3t","id":1},{"type":"plain","value":"hello"},` +
+		`{"items":[{"value":"x","type":"secret"}],"type":"secret","value":{"nested":true}},` +
+		`{"z":"<b>","value":"v","type":"secret","value":"last"},"str",1.50,null]`
+	const want = `[{"type":"secret","value":"***","id":1,"redacted":true},{"type":"plain","value":"hello"},` +
+		`{"items":[{"value":"***","type":"secret","redacted":true}],"type":"secret","value":"***","redacted":true},` +
+		`{"z":"\u003cb\u003e","value":"***","type":"secret","redacted":true},"str",1.50,null]`
+	fn := func(obj map[string]json.RawMessage) map[string]json.RawMessage {
+		if string(obj["type"]) != `"secret"` {
+			return obj
+		}
+		obj["value"] = json.RawMessage(`"***"`)
+		obj["redacted"] = json.RawMessage(" true ")
+		return obj
+	}
+	dst, err := sanitize.MessageObject(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamObject(buf, iotest.OneByteReader(strings.NewReader(input)), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMessageObjectErrors(t *testing.T) {
+	keep := func(obj map[string]json.RawMessage) map[string]json.RawMessage { return obj }
+	for _, input := range []string{`{"a":1`, `[{"a":}]`, `{"a" 1}`, `[1,]`} {
+		if _, err := sanitize.MessageObject(nil, []byte(input), keep); err == nil {
+			t.Errorf("%q: no error for malformed input", input)
+		}
+	}
+	bad := func(obj map[string]json.RawMessage) map[string]json.RawMessage {
+		return map[string]json.RawMessage{"a": json.RawMessage("{")}
+	}
+	if _, err := sanitize.MessageObject(nil, []byte(`{}`), bad); err == nil {
+		t.Error("invalid json returned by ObjectFunc accepted")
+	}
+	drop := func(map[string]json.RawMessage) map[string]json.RawMessage { return nil }
+	dst, err := sanitize.MessageObject(nil, []byte(`{"a":{"b":1}}`), drop)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), `{}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
+
+func TestMessageObjectDeepNesting(t *testing.T) {
+	keep := func(obj map[string]json.RawMessage) map[string]json.RawMessage { return obj }
+	for _, depth := range []int{100, 10001, 1000000} {
+		input := strings.Repeat(`{"a":[`, depth/2) + strings.Repeat("[", depth%2) +
+			strings.Repeat("]", depth%2) + strings.Repeat(`]}`, depth/2)
+		_, err := sanitize.MessageObject(nil, []byte(input), keep)
+		if (err == nil) != (depth <= 10000) {
+			t.Fatalf("MessageObject, depth %d: unexpected error: %v", depth, err)
+		}
+		err = sanitize.StreamObject(ioutil.Discard, strings.NewReader(input), keep)
+		if (err == nil) != (depth <= 10000) {
+			t.Fatalf("StreamObject, depth %d: unexpected error: %v", depth, err)
+		}
+	}
+}
+
+func TestMessageObjectOrder(t *testing.T) {
+	const input = `{"z":1,"b":2,"y":{"q":1,"c":2,"p":3},"a":3,"m":4}`
+	const want = `{"z":1,"b":2,"y":{"q":1,"c":2,"p":3,"_1":0,"_2":0,"_3":0},"a":3,"m":4,"_1":0,"_2":0,"_3":0}`
+	fn := func(obj map[string]json.RawMessage) map[string]json.RawMessage {
+		for _, k := range []string{"_3", "_1", "_2"} {
+			obj[k] = json.RawMessage("0")
+		}
+		return obj
+	}
+	for i := 0; i < 50; i++ {
+		dst, err := sanitize.MessageObject(nil, []byte(input), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != want {
+			t.Fatalf("run %d got:\n%s\nwant:\n%s", i, got, want)
+		}
+	}
+}
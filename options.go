@@ -0,0 +1,203 @@
+package sanitize
+
+import (
+	"errors"
+	"io"
+)
+
+// Options configure processing done by StreamWithOptions and
+// MessageWithOptions. Zero value is a valid configuration matching behavior
+// of StreamFunc and MessageFunc.
+type Options struct {
+	// Keys, if set, is called on each object key.
+	Keys KeyFunc
+
+	// MaxDepth, if positive, limits nesting depth of objects and arrays.
+	// Once payload nesting goes past this limit, processing stops with
+	// an error wrapping ErrMaxDepthExceeded. Output produced up to this
+	// point is still flushed to the writer by StreamWithOptions.
+	MaxDepth int
+
+	// Prefix and Indent, if any of them is non-empty, make output indented
+	// the same way StreamIndent does.
+	Prefix, Indent string
+
+	// Drop, if set, is called on each object member before its value is
+	// written. If it returns true, member is omitted from output entirely:
+	// its key, its value, including any nested objects and arrays, and the
+	// separating comma. Objects with all members dropped become {}.
+	//
+	// For members holding objects and arrays f.Kind is Object or Array,
+	// and Func is not called on values nested in dropped members.
+	Drop func(f Field) bool
+
+	// Nested, if positive, enables sanitizing of json documents embedded
+	// in string values, like {"payload":"{\"ssn\":\"123\"}"}. If Func
+	// does not mask the string, and it holds a valid json object or array,
+	// the embedded document is processed with the same Func and Options,
+	// and the string is replaced with its sanitized compact form. Field
+	// values passed to Func describe locations within the embedded
+	// document.
+	//
+	// Nested limits how many levels of documents embedded into each other
+	// are processed, 1 only descends into strings of the payload itself.
+	Nested int
+
+	// NoHTMLEscape disables escaping of <, > and & in strings of the
+	// output, which by default are written as \u003c, \u003e and \u0026
+	// the same way json.Marshal does. U+2028 and U+2029 are escaped
+	// regardless of this setting.
+	NoHTMLEscape bool
+
+	// Replace, if set, is called on each scalar value before Func. If it
+	// returns true for ok, value is substituted by r written verbatim, and
+	// Func is not called for this value. Zero Replacement is written as
+	// null.
+	Replace func(f Field) (r Replacement, ok bool)
+
+	// MaxTokenSize, if positive, limits size of a single string or number
+	// in bytes, including object keys. Once payload has a longer token,
+	// processing stops with an error wrapping ErrTokenTooLarge.
+	//
+	// For input read from io.Reader this also bounds memory used to buffer
+	// a token, as no more than MaxTokenSize plus a few kilobytes of input
+	// past the last complete token is read ahead. Whitespace between tokens
+	// is not counted, the same way it is not for input passed to
+	// MessageWithOptions.
+	MaxTokenSize int
+
+	// StrictReplacements makes processing stop with an error wrapping
+	// ErrInvalidReplacement once Func returns newValue that is not valid
+	// UTF-8 and is written as a json string. By default each invalid byte
+	// of such newValue is written as \ufffd, the Unicode replacement
+	// character, the same way json.Marshal does.
+	StrictReplacements bool
+
+	// Relaxed makes input accept // line and /* block */ comments, and
+	// trailing commas after the last member of an object or the last
+	// element of an array. Output is still strict json: comments and
+	// trailing commas are dropped. Offsets reported in errors match
+	// positions in the original input.
+	Relaxed bool
+
+	// Subtree, if set, limits processing to values at or below this path:
+	// Func and Replace are only called for values whose Field.Path starts
+	// with Subtree, everything else is copied to output unchanged. For
+	// example, with Subtree of {Key:"data"}, {Key:"attributes"} only values
+	// nested under "attributes" member of top-level "data" object are
+	// sanitized. Array elements of Subtree must have Index set, object
+	// members must have Index of -1.
+	Subtree []PathElem
+
+	// CloseOnError makes processing that stops with an error, like that of
+	// malformed or truncated input, close all open objects and arrays, so
+	// output is still a valid, if incomplete, json. MessageWithOptions
+	// then returns such output along with the error.
+	CloseOnError bool
+
+	// ErrorMarker, if set along with CloseOnError, is written before
+	// closing open objects and arrays to mark output as incomplete: as a
+	// string element of the innermost open array, or as both key and
+	// string value of a member of the innermost open object.
+	ErrorMarker string
+
+	// NormalizeWhitespace makes string values that are not substituted by
+	// Func or Replace have each run of Unicode whitespace, like newlines
+	// and tabs of pasted multi-line text, replaced by a single space.
+	// Replacement values and object keys are written as is. Values outside
+	// of Subtree are not changed either.
+	NormalizeWhitespace bool
+
+	// StripControlChars makes string values that are not substituted by
+	// Func or Replace have control characters removed, except for tabs:
+	// newlines, carriage returns, escape characters starting ANSI terminal
+	// sequences and the like, which can be used to forge log records. With
+	// NormalizeWhitespace also set, whitespace is folded first, so line
+	// breaks become spaces rather than being removed. Replacement values
+	// and object keys are written as is.
+	StripControlChars bool
+
+	// BufSize, if positive, sets size of the buffer StreamWithOptions uses
+	// for output, which is 4096 bytes by default. The same size is used to
+	// buffer reads from input: input is then read in chunks of BufSize
+	// bytes, and up to BufSize bytes past the end of the payload may be
+	// consumed from the reader. Larger buffers reduce the number of calls
+	// to the underlying writer and reader, which helps with large payloads.
+	BufSize int
+
+	// Strict makes processing reject input that is valid json, but is
+	// likely a result of a bug or tampering: input holding anything but
+	// exactly one json value fails with ErrTrailingData or *SyntaxError,
+	// as StreamSingle does; object with duplicate keys fails with an error
+	// wrapping ErrDuplicateKey; input that is not valid UTF-8 fails with
+	// an error wrapping ErrInvalidUTF8. Output produced before the failure
+	// is flushed to the writer by StreamWithOptions, and returned by
+	// MessageWithOptions if CloseOnError is set.
+	Strict bool
+
+	// OnObject, if set, is called once each object is complete, with keys
+	// of its members in the order they appear in the payload, as they are
+	// before Keys is applied. It is called on nested objects before the
+	// objects they are nested in, and is not called on objects nested in
+	// members omitted by Drop. Empty objects are reported with nil keys.
+	// Function may retain keys.
+	OnObject func(keys []string)
+
+	// MaxOutputBytes, if positive, limits size of the output. Once writing
+	// the next token would exceed it, processing stops with an error
+	// wrapping ErrOutputTooLarge, and output ends with the last complete
+	// token that fits. With CloseOnError closing brackets are still written
+	// then, and may exceed the limit.
+	MaxOutputBytes int64
+}
+
+// ErrMaxDepthExceeded is returned when payload nesting depth exceeds
+// Options.MaxDepth.
+var ErrMaxDepthExceeded = errors.New("sanitize: maximum nesting depth exceeded")
+
+// ErrTokenTooLarge is returned when payload has a token longer than
+// Options.MaxTokenSize.
+var ErrTokenTooLarge = errors.New("sanitize: token too large")
+
+// ErrInvalidReplacement is returned when Func returns newValue that is not
+// valid UTF-8 and Options.StrictReplacements is set.
+var ErrInvalidReplacement = errors.New("sanitize: replacement is not valid UTF-8")
+
+// ErrDuplicateKey is returned when input object has duplicate keys and
+// Options.Strict is set.
+var ErrDuplicateKey = errors.New("sanitize: duplicate key")
+
+// ErrInvalidUTF8 is returned when input is not valid UTF-8 and Options.Strict
+// is set.
+var ErrInvalidUTF8 = errors.New("sanitize: invalid UTF-8")
+
+// ErrNotArray is returned by StreamArray when payload is not a json array.
+var ErrNotArray = errors.New("sanitize: payload is not a json array")
+
+// KeyFunc is called on each object key. If function returns true for
+// replace, key is substituted by newKey, which is then used as the key of the
+// member value for all further processing, i.e. it is passed to Func as
+// Field.Key.
+//
+// KeyFunc does not check for duplicate keys, so if newKey is the same as
+// some other key of the object, output would have an object with duplicate
+// keys, which is valid json.
+type KeyFunc func(key string) (newKey string, replace bool)
+
+// StreamWithOptions is a variant of StreamFunc with processing configured by
+// opts, which may be nil.
+func StreamWithOptions(w io.Writer, r io.Reader, fn Func, opts *Options) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	return stream(w, r, newState(fn, opts))
+}
+
+// MessageWithOptions is a variant of MessageFunc with processing configured
+// by opts, which may be nil.
+func MessageWithOptions(dst, src []byte, fn Func, opts *Options) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	return message(dst, src, newState(fn, opts))
+}
@@ -0,0 +1,579 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestOptionsKeys(t *testing.T) {
+	const input = `{"user-8675309":{"Msg":"Hi","id":"x"},"user-42":{"id":"y"},"Msg":"Hi"}`
+	const want = `{"user-\"\u003c*\u003e\"":{"********":"********","id":"***"},"user-\"\u003c*\u003e\"":{"id":"***"},"********":"Hi"}`
+	opts := &sanitize.Options{
+		Keys: func(key string) (string, bool) {
+			switch {
+			case strings.HasPrefix(key, "user-"):
+				return `user-"<*>"`, true
+			case key == "Msg":
+				return sanitize.Mask, true
+			}
+			return "", false
+		},
+	}
+	fn := func(f sanitize.Field) (string, bool) {
+		switch {
+		case f.Key == "id" && f.ParentKey == `user-"<*>"`:
+			return "***", true
+		case f.Key == sanitize.Mask && f.Depth == 2:
+			return sanitize.Mask, true
+		}
+		return "", false
+	}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dst) {
+		t.Fatal("invalid output:", string(dst))
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOptionsMaxDepth(t *testing.T) {
+	opts := &sanitize.Options{MaxDepth: 3}
+	fn := sanitize.FieldFunc(fn).Func()
+	if _, err := sanitize.MessageWithOptions(nil, []byte(`{"a":[{"b":"c"}]}`), fn, opts); err != nil {
+		t.Fatal(err)
+	}
+	input := strings.Repeat("[", 1000) + strings.Repeat("]", 1000)
+	buf := new(bytes.Buffer)
+	err := sanitize.StreamWithOptions(buf, strings.NewReader(input), fn, opts)
+	if !errors.Is(err, sanitize.ErrMaxDepthExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if want := "sanitize: maximum nesting depth exceeded: 3"; err.Error() != want {
+		t.Fatalf("got error %q, want %q", err, want)
+	}
+	if got := buf.String(); got != "[[[" {
+		t.Fatalf("unexpected partial output: %q", got)
+	}
+}
+
+func TestOptionsDrop(t *testing.T) {
+	opts := &sanitize.Options{
+		Drop: func(f sanitize.Field) bool {
+			return f.Key == "secret" || f.Key == "token" && f.Kind == sanitize.String
+		},
+	}
+	fn := sanitize.FieldFunc(fn).Func()
+	for _, tc := range []struct{ input, want string }{
+		{`{"secret":1,"a":"x","b":2}`, `{"a":"********","b":2}`},
+		{`{"b":2,"secret":1,"c":"x"}`, `{"b":2,"c":"********"}`},
+		{`{"b":2,"a":"x","secret":1}`, `{"b":2,"a":"********"}`},
+		{`{"secret":1}`, `{}`},
+		{`{"secret":1,"secret":"x"}`, `{}`},
+		{`{"secret":{"a":[{"b":"c"}]},"d":[]}`, `{"d":[]}`},
+		{`[{"secret":[1,[2]]},{"b":{"secret":null}},{"token":"x","token":1}]`, `[{},{"b":{}},{"token":1}]`},
+		{`{"a":{"secret":true},"b":1}{"secret":2}`, "{\"a\":{},\"b\":1}\n{}"},
+	} {
+		got, err := sanitize.MessageWithOptions(nil, []byte(tc.input), fn, opts)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.input, err)
+		}
+		if string(got) != tc.want {
+			t.Errorf("%s:\ngot:  %s\nwant: %s", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsDropIndent(t *testing.T) {
+	const input = `{"secret":1,"a":{"secret":"x"},"b":[1],"c":{"x":1,"secret":[]}}`
+	opts := &sanitize.Options{
+		Indent: "  ",
+		Drop:   func(f sanitize.Field) bool { return f.Key == "secret" },
+	}
+	got, err := sanitize.MessageWithOptions(nil, []byte(input), sanitize.FieldFunc(fn).Func(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := new(bytes.Buffer)
+	if err := json.Indent(want, []byte(`{"a":{},"b":[1],"c":{"x":1}}`), "", "  "); err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want.String() {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestOptionsNested(t *testing.T) {
+	const input = `{"Msg":"{\"a\":1}","payload":"{\"ssn\":\"123\",\"b\":\"<x>\"}",` +
+		`"list":[" [{\"c\":\"d\"}] ","{bad","[1,2]x","\"a\"","{}"],` +
+		`"deep":"{\"x\":\"{\\\"a\\\":\\\"y\\\"}\"}"}`
+	fn := func(f sanitize.Field) (string, bool) {
+		switch f.Key {
+		case "Msg", "ssn", "a", "b", "c":
+			return sanitize.Mask, true
+		}
+		return "", false
+	}
+	for _, tc := range []struct {
+		nested int
+		want   string
+	}{
+		{0, `{"Msg":"********","payload":"{\"ssn\":\"123\",\"b\":\"\u003cx\u003e\"}",` +
+			`"list":[" [{\"c\":\"d\"}] ","{bad","[1,2]x","\"a\"","{}"],` +
+			`"deep":"{\"x\":\"{\\\"a\\\":\\\"y\\\"}\"}"}`},
+		{1, `{"Msg":"********","payload":"{\"ssn\":\"********\",\"b\":\"********\"}",` +
+			`"list":["[{\"c\":\"********\"}]","{bad","[1,2]x","\"a\"","{}"],` +
+			`"deep":"{\"x\":\"{\\\"a\\\":\\\"y\\\"}\"}"}`},
+		{2, `{"Msg":"********","payload":"{\"ssn\":\"********\",\"b\":\"********\"}",` +
+			`"list":["[{\"c\":\"********\"}]","{bad","[1,2]x","\"a\"","{}"],` +
+			`"deep":"{\"x\":\"{\\\"a\\\":\\\"********\\\"}\"}"}`},
+	} {
+		opts := &sanitize.Options{Nested: tc.nested}
+		dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(dst) {
+			t.Fatal("invalid output:", string(dst))
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("Nested: %d\ngot:\n%s\nwant:\n%s", tc.nested, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsNestedLimit(t *testing.T) {
+	// string with json embedded 15 times, escaping doubles its size on
+	// each level
+	doc := `{"x":"y"}`
+	for i := 0; i < 15; i++ {
+		b, err := json.Marshal(map[string]string{"x": doc})
+		if err != nil {
+			t.Fatal(err)
+		}
+		doc = string(b)
+	}
+	fn := sanitize.FieldFunc(fn).Func()
+	dst, err := sanitize.MessageWithOptions(nil, []byte(doc), fn, &sanitize.Options{Nested: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dst) {
+		t.Fatal("invalid output")
+	}
+	if len(dst) != len(doc) {
+		t.Fatalf("unexpected output size %d, want %d", len(dst), len(doc))
+	}
+}
+
+func TestOptionsNoHTMLEscape(t *testing.T) {
+	const input = `{"<a>":"x & y","d":"<script>\u2028</script>","Msg":"Hi"}`
+	fn := sanitize.FieldFunc(fn).Func()
+	for _, escape := range []bool{true, false} {
+		opts := &sanitize.Options{NoHTMLEscape: !escape}
+		dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(dst) {
+			t.Fatal("invalid output:", string(dst))
+		}
+		// keys are kept in order by the struct, so output should match
+		// encoding/json byte for byte
+		v := struct {
+			A   string `json:"<a>"`
+			D   string `json:"d"`
+			Msg string
+		}{"x & y", "<script>\u2028</script>", sanitize.Mask}
+		buf := new(bytes.Buffer)
+		enc := json.NewEncoder(buf)
+		enc.SetEscapeHTML(escape)
+		if err := enc.Encode(v); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := string(dst), strings.TrimSuffix(buf.String(), "\n"); got != want {
+			t.Errorf("escape HTML: %v\ngot:\n%s\nwant:\n%s", escape, got, want)
+		}
+	}
+}
+
+func TestOptionsMaxTokenSize(t *testing.T) {
+	opts := &sanitize.Options{MaxTokenSize: 1 << 10}
+	fn := sanitize.FieldFunc(fn).Func()
+	ok := `{"a":"` + strings.Repeat("x", 1<<10) + `","b":` + strings.Repeat("1", 1<<10) + `}`
+	if _, err := sanitize.MessageWithOptions(nil, []byte(ok), fn, opts); err != nil {
+		t.Fatal(err)
+	}
+	if err := sanitize.StreamWithOptions(ioutil.Discard, strings.NewReader(ok), fn, opts); err != nil {
+		t.Fatal(err)
+	}
+	for _, input := range []string{
+		`{"a":"` + strings.Repeat("x", 1<<10+1) + `"}`,
+		`{"` + strings.Repeat("x", 1<<10+1) + `":1}`,
+		`[` + strings.Repeat("1", 1<<10+1) + `]`,
+		`{"a":"` + strings.Repeat(" ", 1<<10+1) + `"}`,
+	} {
+		if _, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts); !errors.Is(err, sanitize.ErrTokenTooLarge) {
+			t.Errorf("Message: unexpected error: %v", err)
+		}
+		if err := sanitize.StreamWithOptions(ioutil.Discard, strings.NewReader(input), fn, opts); !errors.Is(err, sanitize.ErrTokenTooLarge) {
+			t.Errorf("Stream: unexpected error: %v", err)
+		}
+	}
+	// whitespace between tokens does not count towards the limit
+	spaced := `{"a":"` + strings.Repeat(" ", 900) + `",` + strings.Repeat(" \n\t", 10000) + `"b":` +
+		strings.Repeat(" ", 10000) + `[` + strings.Repeat("1", 1<<10) + `]}`
+	if _, err := sanitize.MessageWithOptions(nil, []byte(spaced), fn, opts); err != nil {
+		t.Fatalf("Message: %v", err)
+	}
+	if err := sanitize.StreamWithOptions(ioutil.Discard, strings.NewReader(spaced), fn, opts); err != nil {
+		t.Fatalf("Stream: %v", err)
+	}
+	// huge string must not be read in full
+	r := &countingReader{r: io.MultiReader(strings.NewReader(`{"a":"`), infiniteReader('x'))}
+	err := sanitize.StreamWithOptions(ioutil.Discard, r, fn, opts)
+	if !errors.Is(err, sanitize.ErrTokenTooLarge) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.n > 1<<10+8<<10 {
+		t.Fatalf("read %d bytes of input", r.n)
+	}
+}
+
+func TestOptionsStrictReplacements(t *testing.T) {
+	const input = `{"a":"пароль","b":true,"c":"ok"}`
+	// buggy masker cutting a multibyte character in half
+	fn := func(f sanitize.Field) (string, bool) {
+		if f.Key == "c" {
+			return "", false
+		}
+		return f.Value[:3], true
+	}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), `{"a":"п\ufffd","b":"tru","c":"ok"}`; got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	opts := &sanitize.Options{StrictReplacements: true}
+	if _, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts); !errors.Is(err, sanitize.ErrInvalidReplacement) {
+		t.Fatalf("Message: unexpected error: %v", err)
+	}
+	if err := sanitize.StreamWithOptions(ioutil.Discard, strings.NewReader(input), fn, opts); !errors.Is(err, sanitize.ErrInvalidReplacement) {
+		t.Fatalf("Stream: unexpected error: %v", err)
+	}
+	const valid = `{"b":true,"c":"ok"}`
+	if _, err := sanitize.MessageWithOptions(nil, []byte(valid), fn, opts); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOptionsSubtree(t *testing.T) {
+	const input = `{"Msg":"a","data":{"id":"b","attributes":{"Msg":"c","list":["d",{"Msg":"e"}]},` +
+		`"attributes2":{"Msg":"f"}},"list":[{"x":"g"},{"x":"h"}]}`
+	const want = `{"Msg":"a","data":{"id":"b","attributes":{"Msg":"********","list":["d",{"Msg":"********"}]},` +
+		`"attributes2":{"Msg":"f"}},"list":[{"x":"g"},{"x":"***"}]}`
+	var seen []string
+	fn := func(f sanitize.Field) (string, bool) {
+		seen = append(seen, f.Value)
+		if f.Key == "x" {
+			return "***", true
+		}
+		return fn(f.Key, f.Value)
+	}
+	opts := &sanitize.Options{Subtree: []sanitize.PathElem{{Key: "data", Index: -1}, {Key: "attributes", Index: -1}}}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := strings.Join(seen, ""), "cde"; got != want {
+		t.Fatalf("fn called on %q, want %q", got, want)
+	}
+	seen = nil
+	opts.Subtree = []sanitize.PathElem{{Key: "list", Index: -1}, {Index: 1}}
+	dst, err = sanitize.MessageWithOptions(nil, dst, fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if got, want := strings.Join(seen, ""), "h"; got != want {
+		t.Fatalf("fn called on %q, want %q", got, want)
+	}
+}
+
+func TestOptionsCloseOnError(t *testing.T) {
+	fn := sanitize.FieldFunc(fn).Func()
+	for _, tc := range []struct {
+		input, marker, want string
+	}{
+		{`{"Msg":"secret","a":[1,{"b":"x","c":`, "", `{"Msg":"********","a":[1,{"b":"********"}]}`},
+		{`{"Msg":"secret","a":[1,{"b":"x","c":`, "TRUNCATED",
+			`{"Msg":"********","a":[1,{"b":"********","TRUNCATED":"TRUNCATED"}]}`},
+		{`[[],[1,2,}`, "<cut>", `[[],[1,2,"\u003ccut\u003e"]]`},
+		{`{"a":{}`, "!", `{"a":{},"!":"!"}`},
+		{`{`, "!", `{"!":"!"}`},
+		{`[1,]`, "", `[1]`},
+		{``, "!", ``},
+		{`{}}`, "!", `{}`},
+	} {
+		opts := &sanitize.Options{CloseOnError: true, ErrorMarker: tc.marker}
+		dst, err := sanitize.MessageWithOptions(nil, []byte(tc.input), fn, opts)
+		if tc.input != "" && err == nil {
+			t.Errorf("%q: no error", tc.input)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%q: Message got:\n%s\nwant:\n%s", tc.input, got, tc.want)
+		}
+		if tc.want != "" && !json.Valid(dst) {
+			t.Errorf("%q: invalid output: %s", tc.input, dst)
+		}
+		buf := new(bytes.Buffer)
+		err2 := sanitize.StreamWithOptions(buf, strings.NewReader(tc.input), fn, opts)
+		if (err == nil) != (err2 == nil) {
+			t.Errorf("%q: Message error %v, Stream error %v", tc.input, err, err2)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("%q: Stream got:\n%s\nwant:\n%s", tc.input, got, tc.want)
+		}
+	}
+	opts := &sanitize.Options{CloseOnError: true, MaxDepth: 2, Indent: "  "}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(`{"a":[1,[2]]}`), fn, opts)
+	if !errors.Is(err, sanitize.ErrMaxDepthExceeded) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got, want := string(dst), "{\n  \"a\": [\n    1\n  ]\n}"; got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+type infiniteReader byte
+
+func (r infiniteReader) Read(p []byte) (int, error) {
+	for i := range p {
+		p[i] = byte(r)
+	}
+	return len(p), nil
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += n
+	return n, err
+}
+
+func TestOptionsNormalizeWhitespace(t *testing.T) {
+	const input = `{"Msg":"a\n\nb","k  \n":"line 1\r\n\tline  2 ","a b":"x y","u":" \u00a0\u3000z\u2028",` +
+		`"list":["p\tq",["  "]],"n":1}`
+	const want = `{"Msg":"keep  this","k  \n":"line 1 line 2 ","a b":"x y","u":" z ","list":["p q",[" "]],"n":1}`
+	fn := sanitize.FieldFunc(func(key, _ string) (string, bool) {
+		if key == "Msg" {
+			return "keep  this", true
+		}
+		return "", false
+	}).Func()
+	opts := &sanitize.Options{NormalizeWhitespace: true}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if dst, err = sanitize.MessageWithOptions(nil, []byte(input), fn, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); !strings.Contains(got, `"line 1\r\n\tline  2 "`) {
+		t.Fatalf("values changed without the option: %s", got)
+	}
+}
+
+func TestOptionsStrict(t *testing.T) {
+	fn := sanitize.FieldFunc(fn).Func()
+	for _, tc := range []struct {
+		input string
+		err   error
+		want  string // output with CloseOnError
+	}{
+		{input: `{"Msg":"x","a":[{"b":1},{"b":2}],"d":"\u0436"}`,
+			want: "{\"Msg\":\"********\",\"a\":[{\"b\":1},{\"b\":2}],\"d\":\"\u0436\"}"},
+		{input: "{\"Msg\":\"x\",\"d\":\"\xd0\xbf\"}", want: "{\"Msg\":\"********\",\"d\":\"\xd0\xbf\"}"},
+		{input: `{"Msg":"x"} {}`, err: sanitize.ErrTrailingData, want: `{"Msg":"********"}`},
+		{input: `{"Msg":"x","a":{"b":1,"b":2}}`, err: sanitize.ErrDuplicateKey,
+			want: `{"Msg":"********","a":{"b":1}}`},
+		{input: "{\"Msg\":\"x\",\"a\":[\"\xff\"]}", err: sanitize.ErrInvalidUTF8,
+			want: `{"Msg":"********","a":[]}`},
+		{input: "{\"Msg\":\"x\",\"a\":\"\xd0\"}", err: sanitize.ErrInvalidUTF8,
+			want: `{"Msg":"********"}`},
+	} {
+		opts := &sanitize.Options{Strict: true, CloseOnError: true}
+		dst, err := sanitize.MessageWithOptions(nil, []byte(tc.input), fn, opts)
+		if !errors.Is(err, tc.err) || (tc.err == nil) != (err == nil) {
+			t.Errorf("%q: Message got error %v, want %v", tc.input, err, tc.err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%q: Message got:\n%s\nwant:\n%s", tc.input, got, tc.want)
+		}
+		buf := new(bytes.Buffer)
+		err = sanitize.StreamWithOptions(buf, iotest.OneByteReader(strings.NewReader(tc.input)), fn, opts)
+		if !errors.Is(err, tc.err) || (tc.err == nil) != (err == nil) {
+			t.Errorf("%q: Stream got error %v, want %v", tc.input, err, tc.err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("%q: Stream got:\n%s\nwant:\n%s", tc.input, got, tc.want)
+		}
+	}
+	if dst, err := sanitize.MessageWithOptions(nil, []byte("{\"a\":1,\"a\":2} \"\xff\""), fn, nil); err != nil {
+		t.Fatalf("got error without Strict: %v, output %q", err, dst)
+	}
+}
+
+func TestOptionsStripControlChars(t *testing.T) {
+	const input = `{"Msg":"a\nb","log":"ok\n2024-01-01 INFO forged","color":"\u001b[31mred\u001b[0m",` +
+		`"tab":"a\tb","k\n":"\u0000\u007f\u0085x","list":["\r\n"]}`
+	fn := sanitize.FieldFunc(func(key, _ string) (string, bool) {
+		if key == "Msg" {
+			return "x\ny", true
+		}
+		return "", false
+	}).Func()
+	for _, tc := range []struct {
+		opts sanitize.Options
+		want string
+	}{
+		{sanitize.Options{StripControlChars: true},
+			`{"Msg":"x\ny","log":"ok2024-01-01 INFO forged","color":"[31mred[0m","tab":"a\tb","k\n":"x","list":[""]}`},
+		{sanitize.Options{StripControlChars: true, NormalizeWhitespace: true},
+			`{"Msg":"x\ny","log":"ok 2024-01-01 INFO forged","color":"[31mred[0m","tab":"a b","k\n":" x","list":[" "]}`},
+	} {
+		dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, &tc.opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !json.Valid(dst) {
+			t.Fatalf("invalid output: %s", dst)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%+v got:\n%s\nwant:\n%s", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestOptionsBufSize(t *testing.T) {
+	buf := new(bytes.Buffer)
+	for _, size := range []int{0, 16, 1 << 16} {
+		buf.Reset()
+		opts := &sanitize.Options{BufSize: size}
+		err := sanitize.StreamWithOptions(buf, strings.NewReader(keyHeavyInput), sanitize.FieldFunc(fn).Func(), opts)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want, err := sanitize.Message(nil, []byte(keyHeavyInput), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(buf.Bytes(), want) {
+			t.Fatalf("BufSize %d: output differs from Message", size)
+		}
+	}
+}
+
+func BenchmarkOptionsBufSize(b *testing.B) {
+	input := "[" + strings.Repeat(keyHeavyInput+",", 99) + keyHeavyInput + "]"
+	fn := sanitize.FieldFunc(fn).Func()
+	for _, size := range []int{4 << 10, 64 << 10} {
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			opts := &sanitize.Options{BufSize: size}
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				if err := sanitize.StreamWithOptions(ioutil.Discard, strings.NewReader(input), fn, opts); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestOptionsOnObject(t *testing.T) {
+	const input = `{"Msg":"x","user":{"name":"a","tags":[{"k":1},{}],"addr":{"city":"b"}},` +
+		`"list":[[{"id":1,"id":2}]],"skip":{"inner":{"x":1}},"n":1}`
+	var got [][]string
+	opts := &sanitize.Options{
+		OnObject: func(keys []string) { got = append(got, keys) },
+		Drop:     func(f sanitize.Field) bool { return f.Key == "skip" },
+	}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), sanitize.FieldFunc(fn).Func(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"Msg":"********","user":{"name":"a","tags":[{"k":1},{}],"addr":{"city":"b"}},` +
+		`"list":[[{"id":1,"id":2}]],"n":1}`; string(dst) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", dst, want)
+	}
+	want := [][]string{
+		{"k"},
+		nil,
+		{"city"},
+		{"name", "tags", "addr"},
+		{"id", "id"},
+		{"Msg", "user", "list", "skip", "n"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got keys:\n%q\nwant:\n%q", got, want)
+	}
+}
+
+func TestOptionsMaxOutputBytes(t *testing.T) {
+	fn := sanitize.FieldFunc(fn).Func()
+	full, err := sanitize.MessageFunc(nil, []byte(keyHeavyInput), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := &sanitize.Options{MaxOutputBytes: 100}
+	buf := new(bytes.Buffer)
+	err = sanitize.StreamWithOptions(buf, strings.NewReader(keyHeavyInput), fn, opts)
+	if !errors.Is(err, sanitize.ErrOutputTooLarge) {
+		t.Fatalf("got %v, want ErrOutputTooLarge", err)
+	}
+	// next token, "identifier" member of the second object, does not fit
+	const want = `[{"identifier":0,"createdAt":1,"updatedAt":2,"isActive":true,"accountOwner":null,"Msg":"********"},{`
+	if got := buf.String(); got != want || !bytes.HasPrefix(full, buf.Bytes()) {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	opts.CloseOnError = true
+	dst, err := sanitize.MessageWithOptions(nil, []byte(keyHeavyInput), fn, opts)
+	if !errors.Is(err, sanitize.ErrOutputTooLarge) {
+		t.Fatalf("got %v, want ErrOutputTooLarge", err)
+	}
+	if got := string(dst); got != want+"}]" || !json.Valid(dst) {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want+"}]")
+	}
+	opts = &sanitize.Options{MaxOutputBytes: int64(len(full))}
+	if dst, err = sanitize.MessageWithOptions(nil, []byte(keyHeavyInput), fn, opts); err != nil {
+		t.Fatalf("output of exactly MaxOutputBytes: %v", err)
+	}
+	if !bytes.Equal(dst, full) {
+		t.Fatal("output differs from unlimited one")
+	}
+}
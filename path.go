@@ -0,0 +1,168 @@
+package sanitize
+
+import (
+	"io"
+	"strconv"
+	"strings"
+)
+
+// PathElem is an element of the value location within json payload: either
+// an object key or an array index.
+type PathElem struct {
+	Key   string // object key
+	Index int    // array index, or -1 for object keys
+}
+
+// PathFunc is called on each string value of object members and array
+// elements with the full location of the value starting from the top-level
+// object or array. For {"a":[{"b":"c"}]} path of value "c" is
+// {Key:"a"}, {Index:0}, {Key:"b"}. Path is only valid during the call and must
+// be copied if retained. If function returns true for mask, value is
+// substituted by newValue.
+type PathFunc func(path []PathElem, value string) (newValue string, mask bool)
+
+// Func returns Func calling fn on string values.
+func (fn PathFunc) Func() Func { return fn.field }
+
+func (fn PathFunc) field(f Field) (string, bool) {
+	if f.Kind != String {
+		return "", false
+	}
+	return fn(f.Path, f.Value)
+}
+
+// StreamPath is a variant of Stream that calls fn with the full path of each
+// value.
+func StreamPath(w io.Writer, r io.Reader, fn PathFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	return StreamFunc(w, r, fn.field)
+}
+
+// MessagePath is a variant of Message that calls fn with the full path of
+// each value.
+func MessagePath(dst, src []byte, fn PathFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	return MessageFunc(dst, src, fn.field)
+}
+
+// GlobFunc returns PathFunc that substitutes with mask string values whose
+// path matches any of patterns.
+//
+// Pattern is a list of segments separated by dots, each segment matches one
+// element of the path:
+//
+//   - "*" matches any single element, either an object key or an array index;
+//   - "**" matches any number of elements, including none;
+//   - any other segment matches an object key equal to it; segment of decimal
+//     digits also matches an array element with such index.
+//
+// Pattern must match the whole path. For example, for payload
+// {"user":{"id":{"ssn":"x"},"cards":[{"pan":"y"}]},"password":"z"} pattern
+// "user.*.ssn" matches "x", "user.cards.0.pan" and "**.pan" match "y",
+// and "*.password" matches nothing, since "z" is a member of the top-level
+// object, while "**.password" matches it. There is no way to escape dots, so keys
+// containing dots can only be matched with wildcards.
+func GlobFunc(mask string, patterns ...string) PathFunc {
+	globs := make([][]string, len(patterns))
+	for i, p := range patterns {
+		globs[i] = strings.Split(p, ".")
+	}
+	return func(path []PathElem, _ string) (string, bool) {
+		for _, g := range globs {
+			if globMatch(g, path) {
+				return mask, true
+			}
+		}
+		return "", false
+	}
+}
+
+// globMatch reports whether path matches glob segments, see GlobFunc
+func globMatch(glob []string, path []PathElem) bool {
+	for len(glob) > 0 {
+		seg := glob[0]
+		if seg == "**" {
+			for i := 0; i <= len(path); i++ {
+				if globMatch(glob[1:], path[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(path) == 0 || !segmentMatch(seg, path[0]) {
+			return false
+		}
+		glob, path = glob[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func segmentMatch(seg string, p PathElem) bool {
+	if seg == "*" {
+		return true
+	}
+	if p.Index < 0 {
+		return seg == p.Key
+	}
+	n, err := strconv.Atoi(seg)
+	return err == nil && n == p.Index && seg == strconv.Itoa(n)
+}
+
+// FromPointers returns PathFunc that substitutes with mask string values
+// whose path is equal to any of pointers, which are JSON Pointers as defined
+// by RFC 6901, like "/user/credentials/password" or "/items/0/secret".
+//
+// Within pointer segments "~1" stands for "/" and "~0" for "~". Segment that
+// is a decimal number without leading zeros matches both the object member
+// with such key and the array element with such index. Empty pointer ""
+// matches top-level string payload. Malformed pointers not starting with "/"
+// never match.
+func FromPointers(mask string, pointers ...string) PathFunc {
+	ptrs := make([][]string, 0, len(pointers))
+	for _, p := range pointers {
+		switch {
+		case p == "":
+			ptrs = append(ptrs, nil)
+		case p[0] == '/':
+			segs := strings.Split(p[1:], "/")
+			for i, s := range segs {
+				segs[i] = pointerUnescaper.Replace(s)
+			}
+			ptrs = append(ptrs, segs)
+		}
+	}
+	return func(path []PathElem, _ string) (string, bool) {
+		for _, p := range ptrs {
+			if pointerMatch(p, path) {
+				return mask, true
+			}
+		}
+		return "", false
+	}
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// pointerMatch reports whether path is equal to unescaped pointer segments,
+// see FromPointers
+func pointerMatch(ptr []string, path []PathElem) bool {
+	if len(ptr) != len(path) {
+		return false
+	}
+	for i, p := range path {
+		if p.Index < 0 {
+			if ptr[i] != p.Key {
+				return false
+			}
+			continue
+		}
+		if n, err := strconv.Atoi(ptr[i]); err != nil || n != p.Index || ptr[i] != strconv.Itoa(n) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,105 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestMessagePath(t *testing.T) {
+	const input = `{"user":{"name":"x","tags":["a","b"]},"org":{"name":"y","user":{"name":"z"}},"name":"w"}`
+	const want = `{"user":{"name":"***","tags":["a","***"]},"org":{"name":"y","user":{"name":"z"}},"name":"w"}`
+	fn := func(path []sanitize.PathElem, value string) (string, bool) {
+		if len(path) < 2 || path[0].Key != "user" {
+			return "", false
+		}
+		switch p := path[1]; {
+		case p.Key == "name" && len(path) == 2:
+			return "***", true
+		case p.Key == "tags" && len(path) == 3 && path[2].Index == 1:
+			return "***", true
+		}
+		return "", false
+	}
+	dst, err := sanitize.MessagePath(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamPath(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestGlobFunc(t *testing.T) {
+	const input = `{"user":{"id":{"ssn":"1"},"cards":[{"pan":"2"},{"pan":"3"}],"ssn":"4"},` +
+		`"password":"5","a":{"b":{"password":"6"}},"0":"7","list":[["8","9"]]}`
+	for _, tc := range []struct {
+		patterns []string
+		want     string
+	}{
+		{[]string{"user.*.ssn"},
+			`{"user":{"id":{"ssn":"*"},"cards":[{"pan":"2"},{"pan":"3"}],"ssn":"4"},"password":"5","a":{"b":{"password":"6"}},"0":"7","list":[["8","9"]]}`},
+		{[]string{"*.password", "password"},
+			`{"user":{"id":{"ssn":"1"},"cards":[{"pan":"2"},{"pan":"3"}],"ssn":"4"},"password":"*","a":{"b":{"password":"6"}},"0":"7","list":[["8","9"]]}`},
+		{[]string{"**.password"},
+			`{"user":{"id":{"ssn":"1"},"cards":[{"pan":"2"},{"pan":"3"}],"ssn":"4"},"password":"*","a":{"b":{"password":"*"}},"0":"7","list":[["8","9"]]}`},
+		{[]string{"user.**.ssn"},
+			`{"user":{"id":{"ssn":"*"},"cards":[{"pan":"2"},{"pan":"3"}],"ssn":"*"},"password":"5","a":{"b":{"password":"6"}},"0":"7","list":[["8","9"]]}`},
+		{[]string{"user.cards.1.pan", "0"},
+			`{"user":{"id":{"ssn":"1"},"cards":[{"pan":"2"},{"pan":"*"}],"ssn":"4"},"password":"5","a":{"b":{"password":"6"}},"0":"*","list":[["8","9"]]}`},
+		{[]string{"user.cards.*.pan", "list.0.1"},
+			`{"user":{"id":{"ssn":"1"},"cards":[{"pan":"*"},{"pan":"*"}],"ssn":"4"},"password":"5","a":{"b":{"password":"6"}},"0":"7","list":[["8","*"]]}`},
+		{[]string{"**"},
+			`{"user":{"id":{"ssn":"*"},"cards":[{"pan":"*"},{"pan":"*"}],"ssn":"*"},"password":"*","a":{"b":{"password":"*"}},"0":"*","list":[["*","*"]]}`},
+		{[]string{"list.00.1", "user.cards", "user.**.x"}, input},
+	} {
+		dst, err := sanitize.MessagePath(nil, []byte(input), sanitize.GlobFunc("*", tc.patterns...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%q got:\n%s\nwant:\n%s", tc.patterns, got, tc.want)
+		}
+	}
+}
+
+func TestFromPointers(t *testing.T) {
+	const input = `{"user":{"credentials":{"password":"1","login":"2"}},"a/b":"3","m~n":{"~1":"4"},` +
+		`"items":[{"secret":"5"},{"secret":"6"}],"0":"7","":"8","list":[["9"]]}`
+	for _, tc := range []struct {
+		pointers []string
+		want     string
+	}{
+		{[]string{"/user/credentials/password"},
+			`{"user":{"credentials":{"password":"*","login":"2"}},"a/b":"3","m~n":{"~1":"4"},"items":[{"secret":"5"},{"secret":"6"}],"0":"7","":"8","list":[["9"]]}`},
+		{[]string{"/a~1b", "/m~0n/~01"},
+			`{"user":{"credentials":{"password":"1","login":"2"}},"a/b":"*","m~n":{"~1":"*"},"items":[{"secret":"5"},{"secret":"6"}],"0":"7","":"8","list":[["9"]]}`},
+		{[]string{"/items/0/secret", "/0", "/", "/list/0/0"},
+			`{"user":{"credentials":{"password":"1","login":"2"}},"a/b":"3","m~n":{"~1":"4"},"items":[{"secret":"*"},{"secret":"6"}],"0":"*","":"*","list":[["*"]]}`},
+		{[]string{"/items/00/secret", "/items/-/secret", "/items", "user/credentials/login", "/user/credentials"}, input},
+	} {
+		dst, err := sanitize.MessagePath(nil, []byte(input), sanitize.FromPointers("*", tc.pointers...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%q got:\n%s\nwant:\n%s", tc.pointers, got, tc.want)
+		}
+	}
+	dst, err := sanitize.MessagePath(nil, []byte(`"x"`), sanitize.FromPointers("*", ""))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != `"*"` {
+		t.Fatalf("got %s, want \"*\"", got)
+	}
+}
@@ -0,0 +1,81 @@
+package sanitize
+
+import "bytes"
+
+// MessagePreserve is a variant of Message that keeps the original formatting
+// of the payload. Only substituted values are changed, all other bytes,
+// including insignificant whitespace and escaping of untouched strings, are
+// copied from src verbatim.
+func MessagePreserve(dst, src []byte, fn FieldFunc) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.w, s.preserve = nopWriter{}, true
+	s.setInput(src)
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	src = s.src
+	if len(dst) > 0 {
+		dst = dst[:0]
+	}
+	buf := bytes.NewBuffer(dst)
+	var last int64
+	for _, e := range s.edits {
+		buf.Write(src[last:e.start])
+		if e.raw {
+			buf.WriteString(e.val)
+		} else {
+			buf.WriteByte('"')
+			writeEscapedString(buf, e.val, true)
+			buf.WriteByte('"')
+		}
+		last = e.end
+	}
+	buf.Write(src[last:])
+	return buf.Bytes(), nil
+}
+
+// edit describes substitution of src[start:end] with val
+type edit struct {
+	start, end int64
+	val        string
+	raw        bool // whether val is written as is, or as json string
+}
+
+// addEdit records substitution of value of the given kind with its original
+// text v that was just consumed by the decoder
+func (s *state) addEdit(kind Kind, v, val string) {
+	end := s.tok.offset()
+	start := end - int64(len(v))
+	if kind == String {
+		start = int64(openingQuote(s.src[:end]))
+	}
+	s.edits = append(s.edits, edit{
+		start: start,
+		end:   end,
+		val:   val,
+		raw:   kind != String && isLiteral(val),
+	})
+}
+
+// openingQuote returns position of the quote opening json string at the tail
+// of b
+func openingQuote(b []byte) int {
+	for i := len(b) - 2; i > 0; i-- {
+		// quotes inside the string are always escaped, while the
+		// opening quote is never preceded by backslash
+		if b[i] == '"' && b[i-1] != '\\' {
+			return i
+		}
+	}
+	return 0
+}
+
+// nopWriter discards everything written to it
+type nopWriter struct{}
+
+func (nopWriter) Write(b []byte) (int, error)       { return len(b), nil }
+func (nopWriter) WriteByte(byte) error              { return nil }
+func (nopWriter) WriteString(s string) (int, error) { return len(s), nil }
@@ -0,0 +1,21 @@
+package sanitize_test
+
+import (
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestMessagePreserve(t *testing.T) {
+	const input = "{\n  \"Msg\" : \"H\\\"i\\\\\",\n  \"Obj\": {\"a\": 1, \"c\": \"\\u0043\"},\n" +
+		"  \"d\":   \"\\u00e9 \\/ untouched\",\n  \"Arr\": [ \"a\", \"b\" ]\n}\n"
+	const want = "{\n  \"Msg\" : \"********\",\n  \"Obj\": {\"a\": 1, \"c\": \"********\"},\n" +
+		"  \"d\":   \"\\u00e9 \\/ untouched\",\n  \"Arr\": [ \"a\", \"b\" ]\n}\n"
+	dst, err := sanitize.MessagePreserve(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
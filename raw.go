@@ -0,0 +1,46 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"unicode/utf8"
+)
+
+// Replacement is a json value substituting sanitized values as is, so values
+// can be replaced with values of another kind, like numbers with 0, or strings
+// with null or {}. Use RawReplacement to create it.
+type Replacement struct{ raw string }
+
+// RawReplacement returns Replacement writing fragment to the output verbatim.
+// It returns an error if fragment is not a single valid json value, or if it
+// is not a valid UTF-8 text.
+// Insignificant whitespace of fragment is removed.
+func RawReplacement(fragment string) (Replacement, error) {
+	if !utf8.ValidString(fragment) {
+		return Replacement{}, fmt.Errorf("sanitize: invalid raw replacement %q: invalid UTF-8", fragment)
+	}
+	var v json.RawMessage
+	if err := json.Unmarshal([]byte(fragment), &v); err != nil {
+		return Replacement{}, fmt.Errorf("sanitize: invalid raw replacement %q: %w", fragment, err)
+	}
+	buf := new(bytes.Buffer)
+	if err := json.Compact(buf, v); err != nil {
+		return Replacement{}, fmt.Errorf("sanitize: invalid raw replacement %q: %w", fragment, err)
+	}
+	return Replacement{raw: buf.String()}, nil
+}
+
+// MustRawReplacement is like RawReplacement but panics if fragment is not
+// a valid json value. It simplifies initialization of global variables
+// holding replacements.
+func MustRawReplacement(fragment string) Replacement {
+	r, err := RawReplacement(fragment)
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
+
+// String returns json text of r.
+func (r Replacement) String() string { return r.raw }
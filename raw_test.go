@@ -0,0 +1,76 @@
+package sanitize_test
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestRawReplacement(t *testing.T) {
+	for _, s := range []string{"", " ", "nul", "01", `"a`, "{}x", "1 2", `{"a":}`, "\"\xff\""} {
+		if _, err := sanitize.RawReplacement(s); err == nil {
+			t.Errorf("%q: invalid fragment accepted", s)
+		} else if !strings.HasPrefix(err.Error(), "sanitize: invalid raw replacement") {
+			t.Errorf("%q: unexpected error: %v", s, err)
+		}
+	}
+	for s, want := range map[string]string{
+		"0":                    "0",
+		" -1.5e3\n":            "-1.5e3",
+		"null":                 "null",
+		`{ "a" : [ 1, "b" ] }`: `{"a":[1,"b"]}`,
+		`"<REDACTED>"`:         `"<REDACTED>"`,
+		"\t[ ]":                "[]",
+		`"line separator"`:     `"line separator"`,
+	} {
+		r, err := sanitize.RawReplacement(s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if r.String() != want {
+			t.Errorf("%q: got %s, want %s", s, r, want)
+		}
+	}
+}
+
+func TestOptionsReplace(t *testing.T) {
+	const input = `{"balance":12.5,"ok":true,"Msg":"Hi","card":{"n":"4242"},"list":[1,"x"],"id":7}`
+	const want = `{"balance":0,"ok":null,"Msg":"********","card":{"n":{"redacted":true}},"list":[0,"x"],"id":7}`
+	zero := sanitize.MustRawReplacement("0")
+	obj := sanitize.MustRawReplacement(`{ "redacted": true }`)
+	opts := &sanitize.Options{
+		Replace: func(f sanitize.Field) (sanitize.Replacement, bool) {
+			switch {
+			case f.Kind == sanitize.Number && f.Key != "id":
+				return zero, true
+			case f.Key == "ok":
+				return sanitize.Replacement{}, true
+			case f.ParentKey == "card":
+				return obj, true
+			}
+			return sanitize.Replacement{}, false
+		},
+	}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), sanitize.FieldFunc(fn).Func(), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(dst) {
+		t.Fatal("invalid output:", string(dst))
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMustRawReplacement(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("MustRawReplacement did not panic on invalid fragment")
+		}
+	}()
+	sanitize.MustRawReplacement("{")
+}
@@ -0,0 +1,44 @@
+package sanitize
+
+import (
+	"bytes"
+	"io"
+)
+
+// NewReader returns io.Reader producing sanitized json payload read from r,
+// the same way Stream does. Payload is processed lazily: r is only read as
+// much as needed to fill the buffer passed to Read.
+//
+// Once processing fails, for example on malformed input, Read returns any
+// output produced so far, then the error. io.EOF is only returned after the
+// whole sanitized payload is read.
+func NewReader(r io.Reader, fn FieldFunc) io.Reader {
+	sr := &reader{}
+	if fn == nil {
+		sr.err = errInvalidArguents
+		return sr
+	}
+	sr.s.fn = fn.field
+	sr.s.w = &sr.buf
+	sr.s.setReader(r)
+	return sr
+}
+
+type reader struct {
+	s   state
+	buf bytes.Buffer // output not yet returned by Read
+	err error        // error to return once buf is drained
+}
+
+func (r *reader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for r.buf.Len() == 0 && r.err == nil {
+		r.err = r.s.step()
+	}
+	if r.buf.Len() > 0 {
+		return r.buf.Read(p)
+	}
+	return 0, r.err
+}
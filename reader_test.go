@@ -0,0 +1,45 @@
+package sanitize_test
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestNewReader(t *testing.T) {
+	r := sanitize.NewReader(iotest.OneByteReader(strings.NewReader(input)), fn)
+	got, err := ioutil.ReadAll(iotest.OneByteReader(r))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if err := iotest.TestReader(sanitize.NewReader(strings.NewReader(input), fn), []byte(want)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewReaderError(t *testing.T) {
+	const input = `{"Msg":"secret","a":[1,2,}`
+	r := sanitize.NewReader(strings.NewReader(input), fn)
+	got, err := ioutil.ReadAll(r)
+	var serr *sanitize.SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("got error %v, want *SyntaxError", err)
+	}
+	if want := `{"Msg":"********","a":[1,2`; string(got) != want {
+		t.Fatalf("got %q before error, want %q", got, want)
+	}
+	if _, err2 := r.Read(make([]byte, 1)); err2 != err {
+		t.Fatalf("repeated Read returned %v, want %v", err2, err)
+	}
+	if _, err := sanitize.NewReader(strings.NewReader(input), nil).Read(make([]byte, 1)); err == nil || err == io.EOF {
+		t.Fatalf("nil fn: got %v, want error", err)
+	}
+}
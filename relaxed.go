@@ -0,0 +1,173 @@
+package sanitize
+
+import "io"
+
+// relaxer turns relaxed json input into a strict one: it replaces // and /* */
+// comments and trailing commas before closing } and ] with whitespace. Each
+// input byte produces exactly one output byte, so offsets within the
+// transformed input match those of the original one.
+type relaxer struct {
+	st      relaxState
+	comma   bool   // whether comma outside of string is pending
+	follows bool   // whether pending comma follows a value, so may be trailing
+	last    byte   // last byte outside of string and comment
+	pending []byte // pending comma followed by whitespace
+}
+
+type relaxState uint8
+
+const (
+	relaxValue        relaxState = iota // outside of string and comment
+	relaxSlash                          // after / that may start a comment
+	relaxString                         // inside string
+	relaxEscape                         // after \ inside string
+	relaxLineComment                    // inside // comment
+	relaxBlockComment                   // inside /* */ comment
+	relaxBlockStar                      // after * inside /* */ comment
+)
+
+// write appends transformed src to dst. Output is delayed after a comma
+// outside of string until the next byte that is neither whitespace nor part
+// of a comment is written, or flush is called.
+func (x *relaxer) write(dst, src []byte) []byte {
+	for _, c := range src {
+		switch x.st {
+		case relaxString:
+			dst = append(dst, c)
+			switch c {
+			case '\\':
+				x.st = relaxEscape
+			case '"':
+				x.st = relaxValue
+			}
+			continue
+		case relaxEscape:
+			dst = append(dst, c)
+			x.st = relaxString
+			continue
+		case relaxLineComment:
+			if c == '\n' {
+				dst = x.space(dst, c)
+				x.st = relaxValue
+			} else {
+				dst = x.space(dst, ' ')
+			}
+			continue
+		case relaxBlockComment, relaxBlockStar:
+			dst = x.space(dst, ' ')
+			switch {
+			case c == '*':
+				x.st = relaxBlockStar
+			case c == '/' && x.st == relaxBlockStar:
+				x.st = relaxValue
+			default:
+				x.st = relaxBlockComment
+			}
+			continue
+		case relaxSlash:
+			switch c {
+			case '/':
+				x.st = relaxLineComment
+				dst = x.space(x.space(dst, ' '), ' ')
+				continue
+			case '*':
+				x.st = relaxBlockComment
+				dst = x.space(x.space(dst, ' '), ' ')
+				continue
+			}
+			// not a comment, leave it for the decoder to report
+			x.st = relaxValue
+			dst = x.value(dst, '/')
+		}
+		switch {
+		case c == '/':
+			x.st = relaxSlash
+		case isSpace(c):
+			dst = x.space(dst, c)
+		case c == ',':
+			if x.comma {
+				dst = append(dst, x.pending...)
+			}
+			x.comma = true
+			x.follows = x.last != 0 && x.last != '[' && x.last != '{' &&
+				x.last != ',' && x.last != ':'
+			x.pending = append(x.pending[:0], c)
+			x.last = c
+		default:
+			dst = x.value(dst, c)
+			if c == '"' {
+				x.st = relaxString
+			}
+		}
+	}
+	return dst
+}
+
+// flush appends to dst any output delayed by write, it should be called once
+// all input is written.
+func (x *relaxer) flush(dst []byte) []byte {
+	if x.st == relaxSlash {
+		x.st = relaxValue
+		return x.value(dst, '/')
+	}
+	if x.comma {
+		x.comma = false
+		dst = append(dst, x.pending...)
+	}
+	return dst
+}
+
+// space appends whitespace byte c to dst, or delays it after pending comma
+func (x *relaxer) space(dst []byte, c byte) []byte {
+	if x.comma {
+		x.pending = append(x.pending, c)
+		return dst
+	}
+	return append(dst, c)
+}
+
+// value appends byte c that is neither whitespace nor part of a comment to
+// dst, preceded by pending comma, which is replaced with whitespace if c
+// closes an object or array
+func (x *relaxer) value(dst []byte, c byte) []byte {
+	x.last = c
+	if x.comma {
+		x.comma = false
+		if x.follows && (c == '}' || c == ']') {
+			x.pending[0] = ' '
+		}
+		dst = append(dst, x.pending...)
+	}
+	return append(dst, c)
+}
+
+// relaxReader transforms relaxed json read from r into a strict one, see
+// relaxer
+type relaxReader struct {
+	r   io.Reader
+	x   relaxer
+	buf []byte
+	out []byte // transformed input, out[off:] is not yet returned by Read
+	off int
+	err error
+}
+
+func (r *relaxReader) Read(p []byte) (int, error) {
+	for r.off == len(r.out) && r.err == nil {
+		if r.buf == nil {
+			r.buf = make([]byte, 4096)
+		}
+		n, err := r.r.Read(r.buf)
+		r.out, r.off = r.x.write(r.out[:0], r.buf[:n]), 0
+		if err == io.EOF {
+			r.out = r.x.flush(r.out)
+		}
+		r.err = err
+	}
+	if r.off < len(r.out) {
+		n := copy(p, r.out[r.off:])
+		r.off += n
+		return n, nil
+	}
+	return 0, r.err
+}
@@ -0,0 +1,82 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestOptionsRelaxed(t *testing.T) {
+	const input = `// config
+{
+	"Msg": "secret", // trailing comment
+	/* block
+	   comment */ "url": "http://example.com/*x*/", /**/
+	"list": [1, 2, /* three */ 3,],
+	"s": "a,]//b", "o": {"a": "x",},
+	"e": [
+		// nothing here
+	],
+}
+/* done **/`
+	const want = `{"Msg":"********","url":"http://example.com/*x*/","list":[1,2,3],` +
+		`"s":"a,]//b","o":{"a":"********"},"e":[]}`
+	fn := sanitize.FieldFunc(fn).Func()
+	opts := &sanitize.Options{Relaxed: true}
+	dst, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamWithOptions(buf, iotest.OneByteReader(strings.NewReader(input)), fn, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := sanitize.MessageWithOptions(nil, []byte(input), fn, nil); err == nil {
+		t.Fatal("strict mode accepted relaxed input")
+	}
+}
+
+func TestOptionsRelaxedErrors(t *testing.T) {
+	fn := sanitize.FieldFunc(fn).Func()
+	opts := &sanitize.Options{Relaxed: true}
+	for _, input := range []string{
+		`[1,,2]`,
+		`[,]`,
+		`{"a":1 /* unterminated`,
+		`[1,/]`,
+		`[1] /`,
+		`[1,`,
+		`{"a":/1}`,
+	} {
+		_, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+		var serr *sanitize.SyntaxError
+		if !errors.As(err, &serr) {
+			t.Errorf("%q: Message: got %v, want *SyntaxError", input, err)
+			continue
+		}
+		err2 := sanitize.StreamWithOptions(new(bytes.Buffer), strings.NewReader(input), fn, opts)
+		if err2 == nil || err2.Error() != err.Error() {
+			t.Errorf("%q: Stream error %v differs from Message error %v", input, err2, err)
+		}
+	}
+	// offsets match positions in the original input
+	const input = "{/* comment */\"a\":1,}x"
+	_, err := sanitize.MessageWithOptions(nil, []byte(input), fn, opts)
+	var serr *sanitize.SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("got %v, want *SyntaxError", err)
+	}
+	if want := int64(strings.IndexByte(input, 'x')); serr.Offset != want && serr.Offset != want+1 {
+		t.Fatalf("got offset %d, want about %d", serr.Offset, want)
+	}
+}
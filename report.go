@@ -0,0 +1,43 @@
+package sanitize
+
+// Match describes a value that FieldFunc chose to substitute.
+type Match struct {
+	Key    string     // key value is stored under
+	Value  string     // original value
+	Path   []PathElem // location of the value, see Field.Path
+	Offset int64      // position of the value within payload
+}
+
+// Report calls fn on each string key/value pair of json payload from src the
+// same way Message does, but instead of producing sanitized output it returns
+// the list of values fn would substitute. It can be used to check which values
+// a new FieldFunc affects before actually applying it.
+func Report(src []byte, fn FieldFunc) ([]Match, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	var matches []Match
+	s := newState(nil, nil)
+	s.w = nopWriter{}
+	s.setInput(src)
+	s.fn = func(f Field) (string, bool) {
+		if f.Index >= 0 || f.Kind != String {
+			return "", false
+		}
+		if _, ok := fn(f.Key, f.Value); ok {
+			path := make([]PathElem, len(f.Path))
+			copy(path, f.Path)
+			matches = append(matches, Match{
+				Key:    f.Key,
+				Value:  f.Value,
+				Path:   path,
+				Offset: s.base + int64(openingQuote(s.src[:s.tok.offset()])),
+			})
+		}
+		return "", false
+	}
+	if err := s.run(); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
@@ -0,0 +1,32 @@
+package sanitize_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestReport(t *testing.T) {
+	const input = `{"Msg": "Hi", "list": [{"a":"x"}, 1, {"b": {"c": "y\"z"}}], "d": "e"}`
+	matches, err := sanitize.Report([]byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sanitize.Match{
+		{Key: "Msg", Value: "Hi", Offset: 8,
+			Path: []sanitize.PathElem{{Key: "Msg", Index: -1}}},
+		{Key: "a", Value: "x", Offset: 28,
+			Path: []sanitize.PathElem{{Key: "list", Index: -1}, {Index: 0}, {Key: "a", Index: -1}}},
+		{Key: "c", Value: `y"z`, Offset: 49,
+			Path: []sanitize.PathElem{{Key: "list", Index: -1}, {Index: 2}, {Key: "b", Index: -1}, {Key: "c", Index: -1}}},
+	}
+	if !reflect.DeepEqual(matches, want) {
+		t.Fatalf("got:\n%+v\nwant:\n%+v", matches, want)
+	}
+	for _, m := range matches {
+		if input[m.Offset] != '"' {
+			t.Errorf("%s: offset %d does not point to the value", m.Key, m.Offset)
+		}
+	}
+}
@@ -4,16 +4,30 @@
 // Note that the main use case for this package is handling of opaque json
 // messages, not anything with the known structure, which is better handled
 // explicitly by sanitizing data and then marshaling sanitized representation.
+//
+// Leading UTF-8 byte order mark of the payload, if present, is skipped and is
+// not written to the output.
+//
+// Empty or whitespace-only payload produces empty output. Top-level value
+// does not have to be an object or an array: for bare strings FieldFunc is
+// called with an empty key, so payload like "secret" can be sanitized too.
+// Func is called on top-level values of all kinds with an empty Field.Path.
+// Payload holding several consecutive top-level values is processed value by
+// value, and values are separated by newlines in the output, so 1 2 does not
+// become 12.
 package sanitize
 
 import (
 	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
-	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
 )
 
 var errInvalidArguents = errors.New("sanitize: fn cannot not be nil")
@@ -22,160 +36,808 @@ var errInvalidArguents = errors.New("sanitize: fn cannot not be nil")
 // a non-nil FieldFunc called on each string key/value pair of json payload.
 //
 // For already allocated messages it is more effective to use Message function.
+//
+// If input is not a valid json, Stream returns *SyntaxError. Output produced
+// up to the malformed part of input is flushed to w.
 func Stream(w io.Writer, r io.Reader, fn FieldFunc) error {
+	_, err := StreamN(w, r, fn)
+	return err
+}
+
+// StreamN is a variant of Stream that also returns the number of bytes
+// written to w, including output flushed before an error.
+func StreamN(w io.Writer, r io.Reader, fn FieldFunc) (int64, error) {
 	if fn == nil {
-		return errInvalidArguents
-	}
-	bw := bufio.NewWriter(w)
-	defer bw.Flush()
-	dec := json.NewDecoder(r)
-	dec.UseNumber()
-	var ds []rune // stack of separators
-	var cnt int
-	var sanitize bool
-	var prevDelim byte
-	var key string
-	for {
-		var delim byte = comma
-		t, err := dec.Token()
-		if err == io.EOF {
-			return bw.Flush()
-		}
-		if err != nil {
-			return err
-		}
-		switch v := t.(type) {
-		case string:
-			if sanitize && prevDelim == ':' {
-				if val, ok := fn(key, v); ok {
-					v = val
-				}
-				sanitize = false
-			}
-			if cnt%2 != 0 && len(ds) > 0 && ds[len(ds)-1] == '{' {
-				delim = colon
-				key = v
-				sanitize = true
-			}
-			bw.WriteByte('"')
-			writeEscapedString(bw, v)
-			bw.WriteByte('"')
-		case bool:
-			if v {
-				bw.WriteString("true")
-			} else {
-				bw.WriteString("false")
-			}
-		case json.Delim:
-			switch v {
-			case '{', '[':
-				ds = append(ds, rune(v))
-			case '}', ']':
-				if len(ds) > 0 {
-					ds = ds[:len(ds)-1]
-				}
-			}
-			cnt = 0
-			prevDelim = 0
-			bw.WriteRune(rune(v))
-		case json.Number:
-			bw.WriteString(string(v))
-		case nil:
-			bw.WriteString("null")
-		default:
-			return fmt.Errorf("unknown json token: %v", v)
-		}
-		cnt++
-		if dec.More() {
-			if v, ok := t.(json.Delim); !ok || v == '}' || v == ']' {
-				prevDelim = delim
-				bw.WriteByte(delim)
-			}
-		}
+		return 0, errInvalidArguents
 	}
+	z := getSanitizer()
+	defer putSanitizer(z)
+	z.Reset()
+	z.s.fn = fn.field
+	z.s.cw = countWriter{w: w}
+	err := stream(&z.s.cw, r, &z.s)
+	return z.s.cw.n, err
+}
+
+// countWriter counts bytes written to w
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	w.n += int64(n)
+	return n, err
 }
 
 // FieldFunc is called on each string attribute of JSON object processed by
-// MessageFunc. Arguments provided are key/value pair of JSON payload, if
-// function returns true for mask, attribute value is substituted by
-// newValue.
+// Message or Stream. Arguments provided are key/value pair of JSON payload,
+// if function returns true for mask, attribute value is substituted by
+// newValue. FieldFunc is also called with an empty key on string payload that
+// is not an object or array, like "secret".
 type FieldFunc func(key, value string) (newValue string, mask bool)
 
+// Func returns Func calling fn on string values of object members.
+func (fn FieldFunc) Func() Func { return fn.field }
+
+func (fn FieldFunc) field(f Field) (string, bool) {
+	if f.Index >= 0 || f.Kind != String {
+		return "", false
+	}
+	return fn(f.Key, f.Value)
+}
+
+// Chain returns FieldFunc calling each of fns in order until one of them
+// returns true for mask, its newValue is then used and the rest of fns are
+// not called. So when several fns match the same field, the first one wins,
+// and each fn always sees the original value, not the one replaced by
+// preceding fns. If none of fns match, field is kept as is.
+//
+// To apply several transformations on top of each other, compose them in a
+// single function passed to ReplaceInValue instead.
+func Chain(fns ...FieldFunc) FieldFunc {
+	return func(key, value string) (string, bool) {
+		for _, fn := range fns {
+			if val, ok := fn(key, value); ok {
+				return val, true
+			}
+		}
+		return "", false
+	}
+}
+
 // Message sanitizes json payload from src and returns its sanitized
 // representation. If dst is non-nil, it is used as a scratch buffer to reduce
 // allocations. fn must be a non-nil FieldFunc called on each string key/value
-// pair of json payload.
+// pair of json payload. If src is not a valid json, Message returns
+// *SyntaxError.
 func Message(dst, src []byte, fn FieldFunc) ([]byte, error) {
 	if fn == nil {
 		return nil, errInvalidArguents
 	}
+	z := getSanitizer()
+	defer putSanitizer(z)
+	z.s.fn = fn.field
+	return message(dst, src, &z.s)
+}
+
+// MessageInto is a variant of Message that writes sanitized payload into dst
+// without growing it and returns the number of bytes written. If sanitized
+// payload does not fit into len(dst) bytes, MessageInto returns
+// io.ErrShortBuffer. dst must not overlap src. Output is never buffered
+// elsewhere, though decoding of src itself still allocates.
+func MessageInto(dst, src []byte, fn FieldFunc) (int, error) {
+	if fn == nil {
+		return 0, errInvalidArguents
+	}
+	z := getSanitizer()
+	defer putSanitizer(z)
+	w := &fixedWriter{b: dst}
+	z.s.fn, z.s.w = fn.field, w
+	z.s.setInput(src)
+	if err := z.s.run(); err != nil {
+		return 0, err
+	}
+	if w.short {
+		return 0, io.ErrShortBuffer
+	}
+	return w.n, nil
+}
+
+// StreamFunc is a variant of Stream that calls fn with extended Field
+// information.
+func StreamFunc(w io.Writer, r io.Reader, fn Func) error {
+	return StreamWithOptions(w, r, fn, nil)
+}
+
+// StreamIndent is a variant of Stream that writes indented output. Each json
+// element begins on a new line beginning with prefix followed by one or more
+// copies of indent according to the indentation nesting, the same way
+// json.Indent does.
+func StreamIndent(w io.Writer, r io.Reader, fn FieldFunc, prefix, indent string) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.pretty, s.prefix, s.indent = true, prefix, indent
+	return stream(w, r, s)
+}
+
+// StreamContext is a variant of Stream that stops processing once ctx is
+// canceled, returning ctx.Err(). Context is checked periodically between
+// tokens, so output flushed to w always ends on the token boundary. Note that
+// StreamContext cannot interrupt a blocked read from r, cancellation is only
+// noticed after the read completes.
+func StreamContext(ctx context.Context, w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.ctx = ctx
+	return stream(w, r, s)
+}
+
+// StreamSingle is a variant of Stream that reads exactly one json value from
+// r. If r has anything but whitespace after that value, StreamSingle returns
+// ErrTrailingData; sanitized value is still flushed to w in this case. Empty
+// input is reported as *SyntaxError wrapping io.ErrUnexpectedEOF.
+func StreamSingle(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.single = true
+	return stream(w, r, s)
+}
+
+// StreamArray is a variant of Stream for payloads holding a top-level array,
+// which may be too large to fit in memory. Once each element of the array is
+// complete, onElement is called with its index, so elements can be counted,
+// or fn can change its behavior for the next element, e.g. to sanitize only
+// a sample of them. Element may not be flushed to w yet when onElement is
+// called.
+//
+// If payload is not an array, StreamArray returns ErrNotArray. Several
+// consecutive top-level arrays are processed one after another, with indices
+// of each array starting from 0.
+func StreamArray(w io.Writer, r io.Reader, fn FieldFunc, onElement func(index int)) error {
+	if fn == nil || onElement == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.onElement = onElement
+	return stream(w, r, s)
+}
+
+// StreamMulti is a variant of Stream for input holding several consecutive
+// json values, possibly with nothing in between, like {"a":1}{"b":2}. Each
+// value is sanitized separately, and written to w followed by a newline.
+func StreamMulti(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.multi = true
+	return stream(w, r, s)
+}
+
+// MessageIndent is a variant of Message that produces indented output the
+// same way StreamIndent does.
+func MessageIndent(dst, src []byte, fn FieldFunc, prefix, indent string) ([]byte, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	s := newState(fn.field, nil)
+	s.pretty, s.prefix, s.indent = true, prefix, indent
+	return message(dst, src, s)
+}
+
+// MessageFunc is a variant of Message that calls fn with extended Field
+// information.
+func MessageFunc(dst, src []byte, fn Func) ([]byte, error) {
+	return MessageWithOptions(dst, src, fn, nil)
+}
+
+// stream runs s over json payload read from r writing result to w
+func stream(w io.Writer, r io.Reader, s *state) error {
+	if size := s.opts.BufSize; s.bw == nil || size > 0 && s.bw.Size() != size {
+		s.bw = bufio.NewWriterSize(w, size)
+	} else {
+		s.bw.Reset(w)
+	}
+	defer s.bw.Flush()
+	s.w = s.bw
+	s.setReader(r)
+	if err := s.run(); err != nil {
+		return err
+	}
+	return s.bw.Flush()
+}
+
+// message runs s over json payload from src appending result to dst
+func message(dst, src []byte, s *state) ([]byte, error) {
 	if len(dst) > 0 {
 		dst = dst[:0]
 	}
-	dec := json.NewDecoder(bytes.NewReader(src))
-	dec.UseNumber()
-	var ds []rune // stack of separators
-	var cnt int
-	var sanitize bool
-	var prevDelim byte
-	var key string
+	buf := bytes.NewBuffer(dst)
+	s.w = buf
+	s.setInput(src)
+	if err := s.run(); err != nil {
+		if s.opts.CloseOnError {
+			return buf.Bytes(), err
+		}
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writer is implemented by both *bufio.Writer and *bytes.Buffer
+type writer interface {
+	io.Writer
+	io.ByteWriter
+	WriteString(s string) (int, error)
+}
+
+// fixedWriter writes into a fixed size buffer
+type fixedWriter struct {
+	b     []byte
+	n     int  // number of bytes written to b
+	short bool // whether some writes did not fit into b
+}
+
+func (w *fixedWriter) Write(p []byte) (int, error) {
+	if w.short || len(p) > len(w.b)-w.n {
+		w.short = true
+		return 0, io.ErrShortBuffer
+	}
+	w.n += copy(w.b[w.n:], p)
+	return len(p), nil
+}
+
+func (w *fixedWriter) WriteString(s string) (int, error) {
+	if w.short || len(s) > len(w.b)-w.n {
+		w.short = true
+		return 0, io.ErrShortBuffer
+	}
+	w.n += copy(w.b[w.n:], s)
+	return len(s), nil
+}
+
+func (w *fixedWriter) WriteByte(c byte) error {
+	if w.short || w.n == len(w.b) {
+		w.short = true
+		return io.ErrShortBuffer
+	}
+	w.b[w.n] = c
+	w.n++
+	return nil
+}
+
+// state holds the state of a single json payload processing
+type state struct {
+	w     writer
+	bw    *bufio.Writer // reused output buffer, if any
+	tok   tokenizer
+	src   []byte           // whole payload, if available
+	base  int64            // offset of decoded input within payload
+	sc    scanner          // tokenizer of src
+	jd    decoderTokenizer // tokenizer of input read from bom
+	bom   bomReader
+	fn    Func
+	opts  Options
+	stack []frame // currently open objects and arrays
+	path  []PathElem
+	skip  int // nesting depth inside of the dropped object member value
+
+	pretty         bool // whether output is indented
+	prefix, indent string
+
+	ctx    context.Context // checked for cancellation if non-nil
+	single bool            // whether only one top-level value is allowed
+	multi  bool            // whether top-level values are separated by newlines
+	inline bool            // whether top-level values are separated by spaces
+
+	onElement func(index int) // called on each top-level array element
+
+	ntok int // number of tokens processed
+	ntop int // number of top-level values written
+
+	maxDepth int // deepest nesting of objects and arrays seen
+
+	fnErr error // error of FieldFuncE, stops processing
+
+	badUTF8 int64 // offset of invalid UTF-8 in src in strict mode, or -1
+
+	objectFn ObjectFunc // if set, payload is processed by runObjects
+	selectFn Selector   // if set, payload is processed by runSelect
+
+	cw countWriter // output writer of StreamN
+
+	preserve bool   // whether to record substitutions as edits
+	edits    []edit // substitutions to apply to src
+}
+
+// ctxCheckInterval is the number of tokens processed between checks of
+// context cancellation
+const ctxCheckInterval = 256
+
+func newState(fn Func, opts *Options) *state {
+	s := &state{fn: fn}
+	if opts != nil {
+		s.opts = *opts
+		s.pretty = opts.Prefix != "" || opts.Indent != ""
+		s.prefix, s.indent = opts.Prefix, opts.Indent
+		s.single = opts.Strict
+	}
+	return s
+}
+
+// frame describes json object or array being processed
+type frame struct {
+	delim     byte   // '{' or '['
+	parentKey string // closest key this object or array is nested under
+	key       string // key of the current object member
+	value     bool   // whether object member key is already consumed
+	n         int    // number of members or elements written
+	prev      string // previous array element, if it was a string
+
+	keys  map[string]struct{} // object keys seen, only tracked in strict mode
+	names []string            // object keys in order, only tracked for OnObject
+}
+
+func (s *state) run() error {
+	if s.selectFn != nil {
+		return s.runSelect()
+	}
+	if s.objectFn != nil {
+		return s.runObjects()
+	}
+	var lw *limitWriter
+	if s.opts.MaxOutputBytes > 0 {
+		lw = &limitWriter{w: s.w, max: s.opts.MaxOutputBytes}
+		s.w = lw
+		defer func() { s.w = lw.w }()
+	}
 	for {
-		var delim byte = comma
-		t, err := dec.Token()
-		if err == io.EOF {
-			return dst, nil
+		err := s.step()
+		if lw != nil && err == nil {
+			err = lw.commit()
 		}
 		if err != nil {
-			return nil, err
+			if err == io.EOF {
+				return nil
+			}
+			if lw != nil {
+				// drop partial output of the failed token; closing
+				// brackets written below are not limited
+				lw.buf.Reset()
+				s.w = lw.w
+			}
+			if s.opts.CloseOnError {
+				s.closeOpen()
+			}
+			return err
 		}
-		switch v := t.(type) {
-		case string:
-			if sanitize && prevDelim == ':' {
-				if val, ok := fn(key, v); ok {
-					v = val
-				}
-				sanitize = false
+	}
+}
+
+// closeOpen writes Options.ErrorMarker, if any, and closes all open objects
+// and arrays, so output written so far becomes a valid json
+func (s *state) closeOpen() {
+	if len(s.stack) == 0 {
+		return
+	}
+	if marker := s.opts.ErrorMarker; marker != "" {
+		top := &s.stack[len(s.stack)-1]
+		top.key = marker
+		s.separator(top)
+		s.w.WriteByte('"')
+		writeEscapedString(s.w, marker, !s.opts.NoHTMLEscape)
+		s.w.WriteByte('"')
+	}
+	for len(s.stack) > 0 {
+		top := s.stack[len(s.stack)-1]
+		s.stack = s.stack[:len(s.stack)-1]
+		s.path = s.path[:len(s.path)-1]
+		if s.pretty && top.n > 0 {
+			s.newline()
+		}
+		if top.delim == '{' {
+			s.w.WriteByte('}')
+		} else {
+			s.w.WriteByte(']')
+		}
+	}
+}
+
+// step processes a single token of the payload, it returns io.EOF once the
+// whole payload is processed
+func (s *state) step() error {
+	n := s.ntok
+	s.ntok++
+	if s.ctx != nil && n%ctxCheckInterval == 0 {
+		if err := s.ctx.Err(); err != nil {
+			return err
+		}
+	}
+	t, err := s.tok.next()
+	if err == io.EOF && (len(s.stack) != 0 || s.skip != 0) {
+		return s.syntaxError(io.ErrUnexpectedEOF)
+	}
+	if err == io.EOF && s.single && n == 0 {
+		return s.syntaxError(io.ErrUnexpectedEOF)
+	}
+	if err == io.EOF {
+		return io.EOF
+	}
+	if s.single && n > 0 && len(s.stack) == 0 && s.skip == 0 {
+		if _, ok := err.(*json.SyntaxError); ok || err == nil {
+			return ErrTrailingData
+		}
+	}
+	if err != nil {
+		return s.syntaxError(err)
+	}
+	if s.badUTF8 >= 0 && s.tok.offset() > s.badUTF8 {
+		return fmt.Errorf("%w at offset %d", ErrInvalidUTF8, s.base+s.badUTF8)
+	}
+	if s.opts.MaxTokenSize > 0 && len(t.v) > s.opts.MaxTokenSize {
+		return fmt.Errorf("%w: %d", ErrTokenTooLarge, s.opts.MaxTokenSize)
+	}
+	if (t.delim == '{' || t.delim == '[') &&
+		s.opts.MaxDepth > 0 && len(s.stack)+s.skip == s.opts.MaxDepth {
+		return fmt.Errorf("%w: %d", ErrMaxDepthExceeded, s.opts.MaxDepth)
+	}
+	if s.skip > 0 {
+		if s.skipToken(t) {
+			s.stack[len(s.stack)-1].value = false
+		}
+		return nil
+	}
+	var top *frame
+	if len(s.stack) > 0 {
+		top = &s.stack[len(s.stack)-1]
+		if top.delim == '[' {
+			s.path[len(s.path)-1] = PathElem{Index: top.n}
+		}
+	}
+	if t.delim == 0 && top != nil && top.delim == '{' && !top.value {
+		v := t.v
+		if s.opts.Strict {
+			if _, ok := top.keys[v]; ok {
+				return fmt.Errorf("%w: %q", ErrDuplicateKey, v)
+			}
+			if top.keys == nil {
+				top.keys = make(map[string]struct{})
 			}
-			if cnt%2 != 0 && len(ds) > 0 && ds[len(ds)-1] == '{' {
-				delim = colon
-				key = v
-				sanitize = true
+			top.keys[v] = struct{}{}
+		}
+		if s.opts.OnObject != nil {
+			top.names = append(top.names, v)
+		}
+		if key, ok := s.replaceKey(v); ok {
+			v = key
+		}
+		top.key = v
+		top.value = true
+		s.path[len(s.path)-1] = PathElem{Key: v, Index: -1}
+		return nil
+	}
+	if t.delim == '}' || t.delim == ']' {
+		if len(s.stack) > 0 {
+			n := s.stack[len(s.stack)-1].n
+			if t.delim == '}' && s.opts.OnObject != nil {
+				s.opts.OnObject(s.stack[len(s.stack)-1].names)
 			}
-			dst = append(dst, '"')
-			dst = appendEscapedString(dst, v)
-			dst = append(dst, '"')
-		case bool:
-			dst = strconv.AppendBool(dst, v)
-		case json.Delim:
-			switch v {
-			case '{', '[':
-				ds = append(ds, rune(v))
-			case '}', ']':
-				if len(ds) > 0 {
-					ds = ds[:len(ds)-1]
+			s.stack = s.stack[:len(s.stack)-1]
+			s.path = s.path[:len(s.path)-1]
+			if s.pretty && n > 0 {
+				s.newline()
+			}
+		}
+		s.w.WriteByte(t.delim)
+	} else {
+		kind, v := t.kind, t.v
+		if top != nil && top.delim == '{' && s.opts.Drop != nil &&
+			s.opts.Drop(s.field(top, kind, v)) {
+			if kind == Object || kind == Array {
+				s.skip = 1
+			} else {
+				top.value = false
+			}
+			return nil
+		}
+		if top == nil && s.onElement != nil && kind != Array {
+			return ErrNotArray
+		}
+		if top != nil {
+			s.separator(top)
+		} else if s.ntop > 0 && s.inline {
+			s.w.WriteByte(' ')
+		} else if s.ntop > 0 && !s.multi {
+			s.w.WriteByte('\n')
+		}
+		if kind == Object || kind == Array {
+			f := frame{delim: v[0]}
+			if top != nil {
+				f.parentKey = top.parentKey
+				if top.delim == '{' {
+					f.parentKey = top.key
 				}
 			}
-			cnt = 0
-			prevDelim = 0
-			dst = append(dst, byte(v))
-		case json.Number:
-			dst = append(dst, string(v)...)
-		case nil:
-			dst = append(dst, "null"...)
-		default:
-			return nil, fmt.Errorf("unknown json token: %v", v)
-		}
-		cnt++
-		if dec.More() {
-			if v, ok := t.(json.Delim); !ok || v == '}' || v == ']' {
-				prevDelim = delim
-				dst = append(dst, delim)
+			s.stack = append(s.stack, f)
+			if len(s.stack) > s.maxDepth {
+				s.maxDepth = len(s.stack)
+			}
+			s.path = append(s.path, PathElem{Index: -1})
+			s.w.WriteString(v)
+			return nil
+		}
+		if err := s.scalar(top, kind, v); err != nil {
+			return err
+		}
+	}
+	// complete value is written
+	if len(s.stack) == 0 {
+		s.ntop++
+		if s.multi {
+			s.w.WriteByte('\n')
+		}
+		return nil
+	}
+	top = &s.stack[len(s.stack)-1]
+	top.value = false
+	if top.delim == '[' {
+		top.prev = ""
+		if t.delim == 0 && t.kind == String {
+			top.prev = t.v
+		}
+		if s.onElement != nil && len(s.stack) == 1 {
+			s.onElement(top.n - 1)
+		}
+	}
+	return nil
+}
+
+// skipToken consumes token t of the dropped object member value, reporting
+// whether the value is complete
+func (s *state) skipToken(t token) bool {
+	switch t.delim {
+	case '{', '[':
+		s.skip++
+	case '}', ']':
+		s.skip--
+	}
+	return s.skip == 0
+}
+
+// replaceKey calls Options.Keys on key, if it is set
+func (s *state) replaceKey(key string) (string, bool) {
+	if s.opts.Keys == nil {
+		return "", false
+	}
+	return s.opts.Keys(key)
+}
+
+// separator writes whatever should precede the next value of top: a comma
+// if it is not the first one, indentation, and the key for object members
+func (s *state) separator(top *frame) {
+	if top.n > 0 {
+		s.w.WriteByte(comma)
+	}
+	top.n++
+	if s.pretty {
+		s.newline()
+	}
+	if top.delim != '{' {
+		return
+	}
+	s.w.WriteByte('"')
+	writeEscapedString(s.w, top.key, !s.opts.NoHTMLEscape)
+	s.w.WriteByte('"')
+	s.w.WriteByte(colon)
+	if s.pretty {
+		s.w.WriteByte(' ')
+	}
+}
+
+// field returns Field describing value v of the given kind, which is either
+// a member or an element of top, or a top-level value if top is nil
+func (s *state) field(top *frame, kind Kind, v string) Field {
+	if top == nil {
+		return Field{Value: v, Kind: kind, Index: -1, Path: s.path, Offset: s.base + s.tok.offset()}
+	}
+	f := Field{
+		Key:       top.key,
+		Value:     v,
+		Kind:      kind,
+		Depth:     len(s.stack),
+		ParentKey: top.parentKey,
+		Index:     -1,
+		Path:      s.path,
+		Offset:    s.base + s.tok.offset(),
+	}
+	if top.delim == '[' {
+		f.Index, f.Prev = top.n-1, top.prev
+	}
+	return f
+}
+
+// inSubtree reports whether the current value is at or below
+// Options.Subtree
+func (s *state) inSubtree() bool {
+	root := s.opts.Subtree
+	if len(root) > len(s.path) {
+		return false
+	}
+	for i, p := range root {
+		if p != s.path[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// scalar writes string, number, bool or null value v of the given kind,
+// possibly replacing it with the result of fn
+func (s *state) scalar(top *frame, kind Kind, v string) error {
+	if !s.inSubtree() {
+		s.writeScalar(kind, v)
+		return nil
+	}
+	if s.opts.Replace != nil {
+		if r, ok := s.opts.Replace(s.field(top, kind, v)); ok {
+			if r.raw == "" {
+				r.raw = "null"
+			}
+			s.w.WriteString(r.raw)
+			return nil
+		}
+	}
+	val, ok := s.fn(s.field(top, kind, v))
+	if s.fnErr != nil {
+		return s.fnErr
+	}
+	if ok {
+		if s.preserve {
+			s.addEdit(kind, v, val)
+		}
+		if kind != String && isLiteral(val) {
+			s.w.WriteString(val)
+			return nil
+		}
+		if s.opts.StrictReplacements && !utf8.ValidString(val) {
+			return fmt.Errorf("%w: %q", ErrInvalidReplacement, val)
+		}
+		kind, v = String, val
+	} else if kind == String && s.opts.Nested > 0 {
+		if doc, ok := s.nested(v); ok {
+			v = doc
+		} else {
+			v = s.cleanString(v)
+		}
+	} else if kind == String {
+		v = s.cleanString(v)
+	}
+	s.writeScalar(kind, v)
+	return nil
+}
+
+// cleanString applies Options.NormalizeWhitespace and
+// Options.StripControlChars to string value v that is not substituted
+func (s *state) cleanString(v string) string {
+	if s.opts.NormalizeWhitespace {
+		v = foldSpace(v)
+	}
+	if s.opts.StripControlChars {
+		v = stripControl(v)
+	}
+	return v
+}
+
+func (s *state) writeScalar(kind Kind, v string) {
+	if kind != String {
+		s.w.WriteString(v)
+		return
+	}
+	s.w.WriteByte('"')
+	writeEscapedString(s.w, v, !s.opts.NoHTMLEscape)
+	s.w.WriteByte('"')
+}
+
+// nested returns sanitized version of json object or array embedded in string
+// v, it reports false if v holds no such document
+func (s *state) nested(v string) (string, bool) {
+	i := 0
+	for i < len(v) && (v[i] == ' ' || v[i] == '\t' || v[i] == '\n' || v[i] == '\r') {
+		i++
+	}
+	if i == len(v) || v[i] != '{' && v[i] != '[' {
+		return "", false
+	}
+	src := []byte(v)
+	if !json.Valid(src) {
+		return "", false
+	}
+	opts := s.opts
+	opts.Nested--
+	opts.Prefix, opts.Indent = "", ""
+	opts.Subtree = nil // embedded document is already within the subtree
+	opts.MaxOutputBytes = 0
+	ns := newState(s.fn, &opts)
+	ns.ctx = s.ctx
+	out, err := message(nil, src, ns)
+	if err != nil {
+		return "", false
+	}
+	return string(out), true
+}
+
+// foldSpace returns s with each run of Unicode whitespace replaced by
+// a single space
+func foldSpace(s string) string {
+	var b strings.Builder
+	space, changed := false, false
+	for i, r := range s {
+		isSpace := unicode.IsSpace(r)
+		if !changed && isSpace && (r != ' ' || space) {
+			changed = true
+			b.Grow(len(s))
+			b.WriteString(s[:i])
+			if space {
+				continue
 			}
 		}
+		if changed && !(isSpace && space) {
+			if isSpace {
+				b.WriteByte(' ')
+			} else {
+				b.WriteRune(r)
+			}
+		}
+		space = isSpace
+	}
+	if !changed {
+		return s
+	}
+	return b.String()
+}
+
+// stripControl returns s with control characters other than tab removed
+func stripControl(s string) string {
+	isControl := func(r rune) bool { return r != '\t' && unicode.IsControl(r) }
+	if strings.IndexFunc(s, isControl) < 0 {
+		return s
+	}
+	return strings.Map(func(r rune) rune {
+		if isControl(r) {
+			return -1
+		}
+		return r
+	}, s)
+}
+
+// isLiteral reports whether s is a json number, true, false or null
+func isLiteral(s string) bool {
+	switch s {
+	case "true", "false", "null":
+		return true
+	}
+	return isValidNumber(s)
+}
+
+// newline starts a new indented line of pretty output
+func (s *state) newline() {
+	s.w.WriteByte('\n')
+	s.w.WriteString(s.prefix)
+	for range s.stack {
+		s.w.WriteString(s.indent)
 	}
 }
 
-// Mask is a placeholder to replace sensitive fields
+// Mask is a placeholder to replace sensitive fields. It is also the default
+// replacement value of json-sanitize command.
 const Mask = "********"
 
 const (
@@ -2,12 +2,19 @@ package sanitize_test
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
+	"math/rand"
 	"os"
+	"reflect"
+	"strconv"
 	"strings"
 	"testing"
+	"testing/quick"
 
 	"github.com/artyom/sanitize"
 )
@@ -34,6 +41,82 @@ func TestMessageEscapedUnicode(t *testing.T) {
 	}
 }
 
+func TestMessageKeys(t *testing.T) {
+	input := `{"plain":1, "q\"uote":2, "a\u0062c":3, "<tag>":4, "юникод":5, "s\\":6}`
+	want := `{"plain":1,"q\"uote":2,"abc":3,"\u003ctag\u003e":4,"юникод":5,"s\\":6}`
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMessageDuplicateKeys(t *testing.T) {
+	const input = `{"a":"x","a":"y","b":{"a":"z","a":{"a":"w"},"a":"v"},"a":{"c":"u"},"a":"t"}`
+	const want = `{"a":"1","a":"2","b":{"a":"3","a":{"a":"4"},"a":"5"},"a":{"c":"u"},"a":"6"}`
+	var values []string
+	fn := func(key, value string) (string, bool) {
+		if key != "a" {
+			return "", false
+		}
+		values = append(values, value)
+		return strconv.Itoa(len(values)), true
+	}
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := []string{"x", "y", "z", "w", "v", "t"}; !reflect.DeepEqual(values, want) {
+		t.Fatalf("fn called with values %q, want %q", values, want)
+	}
+}
+
+func TestNumbersVerbatim(t *testing.T) {
+	nums := []string{
+		"0", "-0", "-0.0", "0.000", "1e400", "-1e400", "1e-400", "1E+2", "1e-07",
+		"6.02214076e23", "12345678901234567890", "-9223372036854775809",
+		"18446744073709551616", "0.1000000000000000055511151231257827",
+		"123456789012345678901234567890.000000000000000000001",
+	}
+	input := `{"n":[` + strings.Join(nums, ",") + `],"x":` + strings.Join(nums, `,"x":`) + `}`
+	var seen []string
+	nfn := func(key string, num json.Number) (string, bool) {
+		seen = append(seen, string(num))
+		return "", false
+	}
+	dst, err := sanitize.MessageFunc(nil, []byte(input), sanitize.NumberFunc(nfn).Func())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != input {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, input)
+	}
+	if !reflect.DeepEqual(seen, nums) {
+		t.Fatalf("NumberFunc called with %q, want %q", seen, nums)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.Stream(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != input {
+		t.Fatalf("Stream got:\n%s\nwant:\n%s", got, input)
+	}
+	for _, n := range nums {
+		dst, err := sanitize.Message(nil, []byte(n), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(dst) != n {
+			t.Errorf("top-level number %s became %s", n, dst)
+		}
+	}
+}
+
 func TestMessage(t *testing.T) {
 	dst, err := sanitize.Message(nil, []byte(input), fn)
 	if err != nil {
@@ -64,6 +147,209 @@ func TestStream(t *testing.T) {
 	}
 }
 
+func TestStreamContext(t *testing.T) {
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamContext(context.Background(), buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != want {
+		t.Fatal("got:", buf)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// reader that cancels context once half of the payload is read
+	input := "[" + strings.Repeat(`{"Msg":"Hi"},`, 10000) + "1]"
+	r := &cancelReader{r: strings.NewReader(input), n: len(input) / 2, cancel: cancel}
+	buf.Reset()
+	if err := sanitize.StreamContext(ctx, buf, r, fn); err != context.Canceled {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if buf.Len() == 0 || buf.Len() > len(input)*3/4 {
+		t.Fatalf("unexpected partial output size: %d", buf.Len())
+	}
+	full, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := buf.String()
+	if !strings.HasPrefix(string(full), s) || !strings.ContainsAny(s[len(s)-1:], `"{}[],:`) {
+		t.Fatalf("output does not end on token boundary: %q", s[len(s)-20:])
+	}
+}
+
+type cancelReader struct {
+	r      io.Reader
+	n      int
+	cancel func()
+}
+
+func (r *cancelReader) Read(p []byte) (int, error) {
+	if len(p) > 512 {
+		p = p[:512]
+	}
+	n, err := r.r.Read(p)
+	if r.n -= n; r.n <= 0 {
+		r.cancel()
+	}
+	return n, err
+}
+
+func TestStreamIndent(t *testing.T) {
+	const input = `{"Msg":"Hi","Obj":{"a":1,"e":{},"b":[]},"Arr":[["a"],{"c":"C"}],"Num":1}`
+	wantBuf := new(bytes.Buffer)
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Indent(wantBuf, dst, ">", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamIndent(buf, strings.NewReader(input), fn, ">", "\t"); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), wantBuf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+// TestRoundTrip checks that sanitizing random json with no-op FieldFunc
+// produces semantically equal json
+func TestRoundTrip(t *testing.T) {
+	noop := func(key, value string) (string, bool) { return "", false }
+	decode := func(b []byte) (interface{}, error) {
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var v interface{}
+		err := dec.Decode(&v)
+		return v, err
+	}
+	check := func(doc randomJSON) bool {
+		src, err := json.Marshal(doc.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		dst, err := sanitize.Message(nil, src, noop)
+		if err != nil {
+			t.Logf("input: %s", src)
+			t.Fatal(err)
+		}
+		buf := new(bytes.Buffer)
+		if err := sanitize.Stream(buf, bytes.NewReader(src), noop); err != nil {
+			t.Logf("input: %s", src)
+			t.Fatal(err)
+		}
+		if !bytes.Equal(dst, buf.Bytes()) {
+			t.Logf("input: %s", src)
+			t.Logf("Message: %s", dst)
+			t.Logf("Stream: %s", buf)
+			return false
+		}
+		v1, err := decode(src)
+		if err != nil {
+			t.Fatal(err)
+		}
+		v2, err := decode(dst)
+		if err != nil {
+			t.Logf("input: %s", src)
+			t.Logf("output: %s", dst)
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(v1, v2) {
+			t.Logf("input: %s", src)
+			t.Logf("output: %s", dst)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// randomJSON implements quick.Generator producing arbitrary json documents
+type randomJSON struct{ v interface{} }
+
+func (randomJSON) Generate(r *rand.Rand, size int) reflect.Value {
+	return reflect.ValueOf(randomJSON{v: randomValue(r, 4)})
+}
+
+func randomValue(r *rand.Rand, depth int) interface{} {
+	n := 6
+	if depth == 0 {
+		n = 4 // scalars only
+	}
+	switch r.Intn(n) {
+	case 0:
+		return randomString(r)
+	case 1:
+		switch r.Intn(3) {
+		case 0:
+			return json.Number(fmt.Sprint(r.Int63() - r.Int63()))
+		case 1:
+			return json.Number(fmt.Sprint(r.NormFloat64() * 1e6))
+		}
+		return json.Number(fmt.Sprintf("%de%d", r.Intn(1000), r.Intn(40)-20))
+	case 2:
+		return r.Intn(2) == 0
+	case 3:
+		return nil
+	case 4:
+		m := make(map[string]interface{})
+		for i := r.Intn(5); i > 0; i-- {
+			m[randomString(r)] = randomValue(r, depth-1)
+		}
+		return m
+	}
+	a := make([]interface{}, r.Intn(5))
+	for i := range a {
+		a[i] = randomValue(r, depth-1)
+	}
+	return a
+}
+
+func randomString(r *rand.Rand) string {
+	const special = "\"\\/<>&\x00\x01\x07\x1f\x7f\t\n\r\u2028\u2029\ufffdюникод😀"
+	rs := []rune(special)
+	b := make([]rune, r.Intn(12))
+	for i := range b {
+		if r.Intn(3) == 0 {
+			b[i] = rs[r.Intn(len(rs))]
+		} else {
+			b[i] = rune(r.Intn(0x80-0x20) + 0x20)
+		}
+	}
+	return string(b)
+}
+
+func TestMessageIndent(t *testing.T) {
+	noop := func(key, value string) (string, bool) { return "", false }
+	check := func(doc randomJSON) bool {
+		src, err := json.Marshal(doc.v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := new(bytes.Buffer)
+		if err := json.Indent(want, src, "//", "  "); err != nil {
+			t.Fatal(err)
+		}
+		got, err := sanitize.MessageIndent(nil, src, noop, "//", "  ")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, want.Bytes()) {
+			t.Logf("input: %s", src)
+			t.Logf("got:\n%s", got)
+			t.Logf("want:\n%s", want)
+			return false
+		}
+		return true
+	}
+	if err := quick.Check(check, &quick.Config{MaxCount: 500}); err != nil {
+		t.Fatal(err)
+	}
+}
+
 func BenchmarkStream(b *testing.B) {
 	b.ReportAllocs()
 	b.SetBytes(int64(len(input)))
@@ -88,6 +374,34 @@ func BenchmarkMessage(b *testing.B) {
 	}
 }
 
+// keyHeavyInput is a payload dominated by object keys
+var keyHeavyInput = func() string {
+	var b strings.Builder
+	b.WriteString(`[`)
+	for i := 0; i < 100; i++ {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		fmt.Fprintf(&b, `{"identifier":%d,"createdAt":1,"updatedAt":2,"isActive":true,"accountOwner":null,"Msg":"Hi"}`, i)
+	}
+	b.WriteString(`]`)
+	return b.String()
+}()
+
+func BenchmarkMessage_Keys(b *testing.B) {
+	src := []byte(keyHeavyInput)
+	dst := make([]byte, len(src))
+	b.ReportAllocs()
+	b.SetBytes(int64(len(src)))
+	b.ResetTimer()
+	var err error
+	for i := 0; i < b.N; i++ {
+		if dst, err = sanitize.Message(dst, src, fn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
 func BenchmarkMessage_Custom(b *testing.B) {
 	name := os.Getenv("JSON")
 	fields := os.Getenv("FIELDS")
@@ -168,3 +482,318 @@ func Example() {
 	// Output:
 	// {"ID":42,"Name":"********","Secret":"********"}
 }
+
+func TestChain(t *testing.T) {
+	var calls []string
+	rule := func(name, key string) sanitize.FieldFunc {
+		return func(k, v string) (string, bool) {
+			calls = append(calls, name+":"+v)
+			if k == key {
+				return name, true
+			}
+			return "", false
+		}
+	}
+	fn := sanitize.Chain(rule("pii", "email"), rule("secret", "email"), rule("secret", "token"))
+	for _, tc := range []struct {
+		key, value, want string
+		ok               bool
+		calls            []string
+	}{
+		{"email", "x@example.com", "pii", true, []string{"pii:x@example.com"}},
+		{"token", "abc", "secret", true, []string{"pii:abc", "secret:abc", "secret:abc"}},
+		{"other", "v", "", false, []string{"pii:v", "secret:v", "secret:v"}},
+	} {
+		calls = nil
+		got, ok := fn(tc.key, tc.value)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("%s: got (%q, %v), want (%q, %v)", tc.key, got, ok, tc.want, tc.ok)
+		}
+		if !reflect.DeepEqual(calls, tc.calls) {
+			t.Errorf("%s: calls %q, want %q", tc.key, calls, tc.calls)
+		}
+	}
+	if _, ok := sanitize.Chain()("k", "v"); ok {
+		t.Fatal("empty Chain masked value")
+	}
+}
+
+func TestStreamSingle(t *testing.T) {
+	for _, tc := range []struct {
+		input, output string
+		err           error
+	}{
+		{input, want, nil},
+		{" \n" + input + "\r\n\t ", want, nil},
+		{`"Hi"`, `"Hi"`, nil},
+		{input + input, want, sanitize.ErrTrailingData},
+		{`{"Msg":"Hi"}garbage`, `{"Msg":"********"}`, sanitize.ErrTrailingData},
+		{`{"Msg":"Hi"} }`, `{"Msg":"********"}`, sanitize.ErrTrailingData},
+		{`1 2`, `1`, sanitize.ErrTrailingData},
+		{``, ``, io.ErrUnexpectedEOF},
+		{`  `, ``, io.ErrUnexpectedEOF},
+		{`{"Msg":`, `{`, io.ErrUnexpectedEOF},
+	} {
+		buf := new(bytes.Buffer)
+		err := sanitize.StreamSingle(buf, strings.NewReader(tc.input), fn)
+		if !errors.Is(err, tc.err) || (err == nil) != (tc.err == nil) {
+			t.Errorf("%q: got error %v, want %v", tc.input, err, tc.err)
+		}
+		if got := buf.String(); got != tc.output {
+			t.Errorf("%q: got output %q, want %q", tc.input, got, tc.output)
+		}
+	}
+}
+
+func TestMessageInto(t *testing.T) {
+	dst := make([]byte, len(want)+10)
+	n, err := sanitize.MessageInto(dst, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst[:n]); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if _, err := sanitize.MessageInto(dst[:len(want)-1], []byte(input), fn); err != io.ErrShortBuffer {
+		t.Fatalf("got error %v, want %v", err, io.ErrShortBuffer)
+	}
+	if n, err := sanitize.MessageInto(dst[:len(want)], []byte(input), fn); err != nil || n != len(want) {
+		t.Fatalf("exact size buffer: got (%d, %v)", n, err)
+	}
+	if _, err := sanitize.MessageInto(dst, []byte(`{"a":`), fn); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("unexpected error for truncated input: %v", err)
+	}
+}
+
+func BenchmarkMessageInto(b *testing.B) {
+	src := []byte(input)
+	dst := make([]byte, len(want))
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := sanitize.MessageInto(dst, src, fn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestStreamArray(t *testing.T) {
+	const input = `[{"Msg":"Hi"}, {"Msg":"Hi","a":[{"Msg":"Hi"}]}, "Hi", {"Msg":"Hi"}, [{"Msg":"Hi"}]]`
+	const want = `[{"Msg":"********"},{"Msg":"Hi","a":[{"Msg":"Hi"}]},"Hi",{"Msg":"Hi"},[{"Msg":"********"}]]`
+	var indices []int
+	next := 0 // index of element being processed
+	sample := func(key, value string) (string, bool) {
+		if next%2 == 0 {
+			return fn(key, value)
+		}
+		return "", false
+	}
+	onElement := func(i int) {
+		indices = append(indices, i)
+		next = i + 1
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamArray(buf, strings.NewReader(input), sample, onElement); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := []int{0, 1, 2, 3, 4}; !reflect.DeepEqual(indices, want) {
+		t.Fatalf("onElement called with %v, want %v", indices, want)
+	}
+	for _, input := range []string{`{"Msg":"Hi"}`, `"Hi"`, `[] {}`} {
+		err := sanitize.StreamArray(ioutil.Discard, strings.NewReader(input), fn, func(int) {})
+		if !errors.Is(err, sanitize.ErrNotArray) {
+			t.Errorf("%s: got %v, want ErrNotArray", input, err)
+		}
+	}
+}
+
+func TestStreamMulti(t *testing.T) {
+	const input = `{"a":"x"}{"Msg":"Hi","b":"y"}[{"c":"z"}]"Hi"1 true{}` + "\n\n" + `null{"a":[]}`
+	const want = `{"a":"********"}` + "\n" +
+		`{"Msg":"********","b":"********"}` + "\n" +
+		`[{"c":"********"}]` + "\n" +
+		`"Hi"` + "\n" + `1` + "\n" + `true` + "\n" + `{}` + "\n" + `null` + "\n" +
+		`{"a":[]}` + "\n"
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamMulti(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var v interface{}
+		if err := dec.Decode(&v); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestStreamN(t *testing.T) {
+	buf := new(bytes.Buffer)
+	n, err := sanitize.StreamN(buf, strings.NewReader(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if n != int64(len(want)) {
+		t.Fatalf("got %d bytes written, want %d", n, len(want))
+	}
+	buf.Reset()
+	n, err = sanitize.StreamN(buf, strings.NewReader(`{"Msg":"secret","a":[1,`), fn)
+	if err == nil {
+		t.Fatal("truncated input processed without error")
+	}
+	if n != int64(buf.Len()) || n == 0 {
+		t.Fatalf("got %d bytes written, output is %d bytes", n, buf.Len())
+	}
+}
+
+func TestEmptyAndScalarInput(t *testing.T) {
+	var calls []string
+	fn := func(key, value string) (string, bool) {
+		calls = append(calls, key+"="+value)
+		if value == "secret" {
+			return sanitize.Mask, true
+		}
+		return "", false
+	}
+	for _, tc := range []struct {
+		input, want string
+		calls       []string // FieldFunc calls
+	}{
+		{"", "", nil},
+		{" \n\t\r ", "", nil},
+		{"\xef\xbb\xbf", "", nil},
+		{"42", "42", nil},
+		{" -1.50e3 ", "-1.50e3", nil},
+		{"true", "true", nil},
+		{"null", "null", nil},
+		{`"hi"`, `"hi"`, []string{"=hi"}},
+		{`"secret"`, `"********"`, []string{"=secret"}},
+		{` "a<b\u0041" `, `"a\u003cbA"`, []string{"=a<bA"}},
+		{"1 2", "1\n2", nil},
+		{`"a""secret"`, "\"a\"\n\"********\"", []string{"=a", "=secret"}},
+		{`{}[] 3`, "{}\n[]\n3", nil},
+	} {
+		for _, stream := range []bool{false, true} {
+			calls = nil
+			var got string
+			if stream {
+				buf := new(bytes.Buffer)
+				if err := sanitize.Stream(buf, strings.NewReader(tc.input), fn); err != nil {
+					t.Fatalf("%q: %v", tc.input, err)
+				}
+				got = buf.String()
+			} else {
+				dst, err := sanitize.Message(nil, []byte(tc.input), fn)
+				if err != nil {
+					t.Fatalf("%q: %v", tc.input, err)
+				}
+				got = string(dst)
+			}
+			if got != tc.want {
+				t.Errorf("%q: stream %v got %q, want %q", tc.input, stream, got, tc.want)
+			}
+			if !reflect.DeepEqual(calls, tc.calls) {
+				t.Errorf("%q: stream %v fn calls %q, want %q", tc.input, stream, calls, tc.calls)
+			}
+		}
+	}
+	var kinds []sanitize.Kind
+	_, err := sanitize.MessageFunc(nil, []byte(`1 true null "s" {"a":[2]}`), func(f sanitize.Field) (string, bool) {
+		if f.Key == "" && f.Index < 0 && len(f.Path) == 0 {
+			kinds = append(kinds, f.Kind)
+		}
+		return "", false
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []sanitize.Kind{sanitize.Number, sanitize.Bool, sanitize.Null, sanitize.String}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("Func called on top-level %v, want %v", kinds, want)
+	}
+}
+
+func TestEscapedSolidus(t *testing.T) {
+	const input = `{"a/b":"x","a\/c":"y","\/":"z","url":"http:\/\/example.com\/p","arr":["\/"],"a\\\/d":"w"}`
+	const want = `{"a/b":"***","a/c":"***","/":"***","url":"http://example.com/p","arr":["/"],"a\\/d":"***"}`
+	var keys []string
+	fn := func(key, value string) (string, bool) {
+		keys = append(keys, key)
+		if strings.Contains(key, "/") && !strings.Contains(value, "/") {
+			return "***", true
+		}
+		return "", false
+	}
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	if want := []string{"a/b", "a/c", "/", "url", `a\/d`}; !reflect.DeepEqual(keys, want) {
+		t.Fatalf("fn called with keys %q, want %q", keys, want)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.Stream(buf, strings.NewReader(input), fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("Stream got:\n%s\nwant:\n%s", got, want)
+	}
+	var v1, v2 interface{}
+	if err := json.Unmarshal([]byte(input), &v1); err != nil {
+		t.Fatal(err)
+	}
+	dst, err = sanitize.Message(nil, []byte(input), func(string, string) (string, bool) { return "", false })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(dst, &v2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(v1, v2) {
+		t.Fatalf("round trip changed payload: %s", dst)
+	}
+}
+
+func TestSeparators(t *testing.T) {
+	for _, tc := range []struct {
+		input, want string
+	}{
+		{`{"a":"x"}`, `{"a":"*"}`},
+		{`{}`, `{}`},
+		{` { } `, `{}`},
+		{`{"n":1,"a":"x"}`, `{"n":1,"a":"*"}`},
+		{`{"a":"x","b":"y"}`, `{"a":"*","b":"*"}`},
+		{`["x",{"a":"x"}]`, `["x",{"a":"*"}]`},
+		{`[{},{}]`, `[{},{}]`},
+		{`[[],{"a":{}},{"a":[]}]`, `[[],{"a":{}},{"a":[]}]`},
+		{`{"o":{"a":"x"},"l":[{"a":"x"}],"a":"x"}`, `{"o":{"a":"*"},"l":[{"a":"*"}],"a":"*"}`},
+		{`[{"a":"x","b":[{"a":"x"}]}]`, `[{"a":"*","b":[{"a":"*"}]}]`},
+	} {
+		dst, err := sanitize.Message(nil, []byte(tc.input), sanitize.AllStrings("*"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != tc.want {
+			t.Errorf("%s: Message got %s, want %s", tc.input, got, tc.want)
+		}
+		buf := new(bytes.Buffer)
+		if err := sanitize.Stream(buf, strings.NewReader(tc.input), sanitize.AllStrings("*")); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != tc.want {
+			t.Errorf("%s: Stream got %s, want %s", tc.input, got, tc.want)
+		}
+	}
+}
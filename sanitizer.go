@@ -0,0 +1,77 @@
+package sanitize
+
+import (
+	"io"
+	"sync"
+)
+
+// Sanitizer holds buffers reused across calls to its Do method: output
+// buffer and stacks of currently open objects and arrays. Reusing Sanitizer,
+// for example by keeping it in a sync.Pool, saves allocations when many small
+// payloads are processed. Note that json decoder is still created by each
+// call, as it cannot be reset to a new reader.
+//
+// Zero value is ready to use. Sanitizer is not safe for concurrent use, each
+// goroutine must use its own instance.
+type Sanitizer struct {
+	s state
+}
+
+// Do sanitizes json payload read from r writing result to w, the same way
+// Stream does.
+func (z *Sanitizer) Do(w io.Writer, r io.Reader, fn FieldFunc) error {
+	if fn == nil {
+		return errInvalidArguents
+	}
+	z.Reset()
+	z.s.fn = fn.field
+	return stream(w, r, &z.s)
+}
+
+// Reset discards state left by the previous call to Do and drops references
+// to its writer, callback and processed data, so they can be garbage
+// collected while z is idle. Buffers are kept for reuse.
+func (z *Sanitizer) Reset() {
+	bw := z.s.bw
+	if bw != nil {
+		bw.Reset(nil)
+	}
+	stack := z.s.stack[:cap(z.s.stack)]
+	for i := range stack {
+		stack[i] = frame{}
+	}
+	path := z.s.path[:cap(z.s.path)]
+	for i := range path {
+		path[i] = PathElem{}
+	}
+	sc := scanner{stack: z.s.sc.stack[:0], buf: z.s.sc.buf[:0]}
+	z.s = state{bw: bw, stack: stack[:0], path: path[:0], sc: sc}
+}
+
+// Grow preallocates stacks of z to hold depth levels of nested objects and
+// arrays, so processing payloads nested up to depth levels does not
+// reallocate them. Use it for payloads known to be deeply nested, stacks
+// grow as needed otherwise.
+func (z *Sanitizer) Grow(depth int) {
+	if depth > cap(z.s.stack) {
+		z.s.stack = append(make([]frame, 0, depth), z.s.stack...)
+	}
+	if depth > cap(z.s.path) {
+		z.s.path = append(make([]PathElem, 0, depth), z.s.path...)
+	}
+	if depth > cap(z.s.sc.stack) {
+		z.s.sc.stack = append(make([]byte, 0, depth), z.s.sc.stack...)
+	}
+}
+
+// sanitizers is a pool of *Sanitizer used by Stream and Message
+var sanitizers = sync.Pool{New: func() interface{} { return new(Sanitizer) }}
+
+// getSanitizer returns reset Sanitizer from the pool, it should be returned
+// with putSanitizer
+func getSanitizer() *Sanitizer { return sanitizers.Get().(*Sanitizer) }
+
+func putSanitizer(z *Sanitizer) {
+	z.Reset()
+	sanitizers.Put(z)
+}
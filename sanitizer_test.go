@@ -0,0 +1,72 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestSanitizer(t *testing.T) {
+	var z sanitize.Sanitizer
+	z.Grow(100)
+	buf := new(bytes.Buffer)
+	for i := 0; i < 3; i++ {
+		buf.Reset()
+		if err := z.Do(buf, strings.NewReader(input), fn); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+		}
+		// failed call must not affect the next one
+		if err := z.Do(ioutil.Discard, strings.NewReader(`{"a":[{"b":`), fn); err == nil {
+			t.Fatal("truncated input processed without error")
+		}
+	}
+	if err := z.Do(buf, strings.NewReader(input), nil); err == nil {
+		t.Fatal("nil fn accepted")
+	}
+}
+
+func BenchmarkSanitizer(b *testing.B) {
+	var z sanitize.Sanitizer
+	r := strings.NewReader(input)
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		r.Reset(input)
+		if err := z.Do(ioutil.Discard, r, fn); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSanitizerDeep(b *testing.B) {
+	const depth = 500
+	input := strings.Repeat(`{"a":[`, depth) + `"x"` + strings.Repeat(`]}`, depth)
+	for _, grow := range []bool{false, true} {
+		name := "default"
+		if grow {
+			name = "grow"
+		}
+		b.Run(name, func(b *testing.B) {
+			r := strings.NewReader(input)
+			b.ReportAllocs()
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				var z sanitize.Sanitizer
+				if grow {
+					z.Grow(2 * depth)
+				}
+				r.Reset(input)
+				if err := z.Do(ioutil.Discard, r, fn); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package sanitize
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"unicode"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// tokenizer splits json payload into tokens
+type tokenizer interface {
+	// next returns the next token, or io.EOF at the end of input
+	next() (token, error)
+	// offset returns input offset right after the last token returned
+	offset() int64
+}
+
+// token is either a delimiter or a scalar value of json payload
+type token struct {
+	kind  Kind   // kind of the value, Object and Array for delimiters
+	delim byte   // '{', '}', '[' or ']' for delimiters, 0 for scalars
+	v     string // decoded string, or json text of other kinds of values
+}
+
+// decoderTokenizer is a tokenizer over json.Decoder, it is used for payloads
+// read from io.Reader
+type decoderTokenizer struct{ dec *json.Decoder }
+
+func (d *decoderTokenizer) offset() int64 { return d.dec.InputOffset() }
+
+func (d *decoderTokenizer) next() (token, error) {
+	t, err := d.dec.Token()
+	if err != nil {
+		return token{}, err
+	}
+	switch v := t.(type) {
+	case string:
+		return token{kind: String, v: v}, nil
+	case json.Number:
+		return token{kind: Number, v: string(v)}, nil
+	case bool:
+		if v {
+			return token{kind: Bool, v: "true"}, nil
+		}
+		return token{kind: Bool, v: "false"}, nil
+	case nil:
+		return token{kind: Null, v: "null"}, nil
+	case json.Delim:
+		switch v {
+		case '{':
+			return token{kind: Object, delim: '{', v: "{"}, nil
+		case '}':
+			return token{kind: Object, delim: '}', v: "}"}, nil
+		case '[':
+			return token{kind: Array, delim: '[', v: "["}, nil
+		case ']':
+			return token{kind: Array, delim: ']', v: "]"}, nil
+		}
+	}
+	return token{}, fmt.Errorf("unknown json token: %v", t)
+}
+
+// scanner is a tokenizer over payload that is fully in memory. It accepts
+// exactly the same input as json.Decoder and produces the same tokens, but
+// works on src directly, avoiding most of the allocations json.Decoder does.
+type scanner struct {
+	src   []byte
+	pos   int
+	state scanState
+	stack []byte // open objects and arrays
+	buf   []byte // scratch buffer to unquote strings
+
+	// discard makes strings and numbers returned with empty v, they can
+	// be found at src[start:pos] instead
+	discard bool
+	start   int // position of the last returned token
+}
+
+// maxNestingDepth is the nesting depth of objects and arrays json.Decoder
+// accepts, deeper payloads are reported as syntax errors
+const maxNestingDepth = 10000
+
+// scanState mirrors tokenState of json.Decoder: what is expected next
+type scanState uint8
+
+const (
+	scanTopValue scanState = iota
+	scanArrayStart
+	scanArrayValue
+	scanArrayComma
+	scanObjectStart
+	scanObjectKey
+	scanObjectColon
+	scanObjectValue
+	scanObjectComma
+)
+
+func (sc *scanner) reset(src []byte) {
+	sc.src, sc.pos, sc.state, sc.discard = src, 0, scanTopValue, false
+	sc.stack = sc.stack[:0]
+}
+
+func (sc *scanner) offset() int64 { return int64(sc.pos) }
+
+func (sc *scanner) next() (token, error) {
+	for {
+		i := sc.pos
+		for i < len(sc.src) && isSpace(sc.src[i]) {
+			i++
+		}
+		if i == len(sc.src) {
+			// like json.Decoder, don't count trailing whitespace
+			// as consumed
+			return token{}, io.EOF
+		}
+		sc.pos, sc.start = i, i
+		switch c := sc.src[sc.pos]; c {
+		case '{', '[':
+			if !sc.valueAllowed() || len(sc.stack) == maxNestingDepth {
+				return token{}, sc.fail()
+			}
+			sc.pos++
+			sc.stack = append(sc.stack, c)
+			if c == '{' {
+				sc.state = scanObjectStart
+				return token{kind: Object, delim: c, v: "{"}, nil
+			}
+			sc.state = scanArrayStart
+			return token{kind: Array, delim: c, v: "["}, nil
+		case '}':
+			if sc.state != scanObjectStart && sc.state != scanObjectComma {
+				return token{}, sc.fail()
+			}
+			sc.pos++
+			sc.pop()
+			return token{kind: Object, delim: c, v: "}"}, nil
+		case ']':
+			if sc.state != scanArrayStart && sc.state != scanArrayComma {
+				return token{}, sc.fail()
+			}
+			sc.pos++
+			sc.pop()
+			return token{kind: Array, delim: c, v: "]"}, nil
+		case ':':
+			if sc.state != scanObjectColon {
+				return token{}, sc.fail()
+			}
+			sc.pos++
+			sc.state = scanObjectValue
+		case ',':
+			switch sc.state {
+			case scanArrayComma:
+				sc.state = scanArrayValue
+			case scanObjectComma:
+				sc.state = scanObjectKey
+			default:
+				return token{}, sc.fail()
+			}
+			sc.pos++
+		case '"':
+			if sc.state == scanObjectStart || sc.state == scanObjectKey {
+				s, ok := sc.string()
+				if !ok {
+					return token{}, sc.fail()
+				}
+				sc.state = scanObjectColon
+				return token{kind: String, v: s}, nil
+			}
+			fallthrough
+		default:
+			if !sc.valueAllowed() {
+				return token{}, sc.fail()
+			}
+			t, ok := sc.scalar()
+			if !ok {
+				return token{}, sc.fail()
+			}
+			sc.valueEnd()
+			return t, nil
+		}
+	}
+}
+
+func (sc *scanner) valueAllowed() bool {
+	switch sc.state {
+	case scanTopValue, scanArrayStart, scanArrayValue, scanObjectValue:
+		return true
+	}
+	return false
+}
+
+func (sc *scanner) valueEnd() {
+	switch sc.state {
+	case scanArrayStart, scanArrayValue:
+		sc.state = scanArrayComma
+	case scanObjectValue:
+		sc.state = scanObjectComma
+	}
+}
+
+// pop closes the innermost object or array
+func (sc *scanner) pop() {
+	sc.stack = sc.stack[:len(sc.stack)-1]
+	switch {
+	case len(sc.stack) == 0:
+		sc.state = scanTopValue
+	case sc.stack[len(sc.stack)-1] == '[':
+		sc.state = scanArrayComma
+	default:
+		sc.state = scanObjectComma
+	}
+}
+
+// scalar reads string, number, bool or null value starting at sc.pos
+func (sc *scanner) scalar() (token, bool) {
+	switch c := sc.src[sc.pos]; {
+	case c == '"':
+		s, ok := sc.string()
+		return token{kind: String, v: s}, ok
+	case c == '-' || '0' <= c && c <= '9':
+		s, ok := sc.number()
+		return token{kind: Number, v: s}, ok
+	case c == 't':
+		return token{kind: Bool, v: "true"}, sc.literal("true")
+	case c == 'f':
+		return token{kind: Bool, v: "false"}, sc.literal("false")
+	case c == 'n':
+		return token{kind: Null, v: "null"}, sc.literal("null")
+	}
+	return token{}, false
+}
+
+func (sc *scanner) literal(s string) bool {
+	if !bytes.HasPrefix(sc.src[sc.pos:], []byte(s)) {
+		return false
+	}
+	sc.pos += len(s)
+	return true
+}
+
+// number reads json number starting at sc.pos. Like json.Decoder, it stops
+// as soon as number cannot continue, so "01" is read as two numbers.
+func (sc *scanner) number() (string, bool) {
+	b, i := sc.src, sc.pos
+	if b[i] == '-' {
+		i++
+	}
+	switch {
+	case i == len(b):
+		return "", false
+	case b[i] == '0':
+		i++
+	case '1' <= b[i] && b[i] <= '9':
+		for i++; i < len(b) && isDigit(b[i]); i++ {
+		}
+	default:
+		return "", false
+	}
+	if i < len(b) && b[i] == '.' {
+		i++
+		if i == len(b) || !isDigit(b[i]) {
+			return "", false
+		}
+		for i++; i < len(b) && isDigit(b[i]); i++ {
+		}
+	}
+	if i < len(b) && (b[i] == 'e' || b[i] == 'E') {
+		i++
+		if i < len(b) && (b[i] == '+' || b[i] == '-') {
+			i++
+		}
+		if i == len(b) || !isDigit(b[i]) {
+			return "", false
+		}
+		for i++; i < len(b) && isDigit(b[i]); i++ {
+		}
+	}
+	if sc.discard {
+		sc.pos = i
+		return "", true
+	}
+	s := string(b[sc.pos:i])
+	sc.pos = i
+	return s, true
+}
+
+// string reads quoted json string starting at sc.pos and returns it
+// unquoted the same way encoding/json does
+func (sc *scanner) string() (string, bool) {
+	b := sc.src
+	start := sc.pos + 1
+	for i := start; i < len(b); {
+		switch c := b[i]; {
+		case c == '"':
+			sc.pos = i + 1
+			if sc.discard {
+				return "", true
+			}
+			return string(b[start:i]), true
+		case c == '\\' || c < ' ':
+			return sc.unquote(start, i)
+		case c < utf8.RuneSelf:
+			i++
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			if r == utf8.RuneError && size == 1 {
+				return sc.unquote(start, i)
+			}
+			i += size
+		}
+	}
+	return "", false
+}
+
+// unquote is a slow path of string for strings with escape sequences or
+// invalid UTF-8, i is the position of the first such sequence
+func (sc *scanner) unquote(start, i int) (string, bool) {
+	b := sc.src
+	sc.buf = append(sc.buf[:0], b[start:i]...)
+	for i < len(b) {
+		switch c := b[i]; {
+		case c == '"':
+			sc.pos = i + 1
+			if sc.discard {
+				return "", true
+			}
+			return string(sc.buf), true
+		case c < ' ':
+			return "", false
+		case c == '\\':
+			if i+1 == len(b) {
+				return "", false
+			}
+			switch e := b[i+1]; e {
+			case '"', '\\', '/':
+				sc.buf = append(sc.buf, e)
+			case 'b':
+				sc.buf = append(sc.buf, '\b')
+			case 'f':
+				sc.buf = append(sc.buf, '\f')
+			case 'n':
+				sc.buf = append(sc.buf, '\n')
+			case 'r':
+				sc.buf = append(sc.buf, '\r')
+			case 't':
+				sc.buf = append(sc.buf, '\t')
+			case 'u':
+				r := getu4(b[i:])
+				if r < 0 {
+					return "", false
+				}
+				i += 6
+				if utf16.IsSurrogate(r) {
+					if r1 := getu4(b[i:]); r1 >= 0 {
+						if dec := utf16.DecodeRune(r, r1); dec != unicode.ReplacementChar {
+							sc.buf = appendRune(sc.buf, dec)
+							i += 6
+							continue
+						}
+					}
+					r = unicode.ReplacementChar
+				}
+				sc.buf = appendRune(sc.buf, r)
+				continue
+			default:
+				return "", false
+			}
+			i += 2
+		case c < utf8.RuneSelf:
+			sc.buf = append(sc.buf, c)
+			i++
+		default:
+			r, size := utf8.DecodeRune(b[i:])
+			sc.buf = appendRune(sc.buf, r)
+			i += size
+		}
+	}
+	return "", false
+}
+
+// fail returns error for malformed input. To report exactly the same errors
+// as for input read from io.Reader, input is tokenized once again with
+// json.Decoder up to the error.
+func (sc *scanner) fail() error {
+	dec := json.NewDecoder(bytes.NewReader(sc.src))
+	dec.UseNumber()
+	depth := 0
+	for {
+		t, err := dec.Token()
+		if err == io.EOF && depth > 0 {
+			err = io.ErrUnexpectedEOF
+		}
+		if err == io.EOF {
+			err = fmt.Errorf("sanitize: invalid json at offset %d", sc.pos)
+		}
+		if err != nil {
+			sc.pos = int(dec.InputOffset())
+			return err
+		}
+		switch t {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+}
+
+// getu4 decodes \uXXXX from the beginning of b, returning -1 if it is not
+// present
+func getu4(b []byte) rune {
+	if len(b) < 6 || b[0] != '\\' || b[1] != 'u' {
+		return -1
+	}
+	var r rune
+	for _, c := range b[2:6] {
+		switch {
+		case '0' <= c && c <= '9':
+			c = c - '0'
+		case 'a' <= c && c <= 'f':
+			c = c - 'a' + 10
+		case 'A' <= c && c <= 'F':
+			c = c - 'A' + 10
+		default:
+			return -1
+		}
+		r = r*16 + rune(c)
+	}
+	return r
+}
+
+func appendRune(b []byte, r rune) []byte {
+	var tmp [utf8.UTFMax]byte
+	n := utf8.EncodeRune(tmp[:], r)
+	return append(b, tmp[:n]...)
+}
+
+func isSpace(c byte) bool { return c == ' ' || c == '\t' || c == '\n' || c == '\r' }
+func isDigit(c byte) bool { return '0' <= c && c <= '9' }
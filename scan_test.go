@@ -0,0 +1,100 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+// TestMessageMatchesStream checks that Message, which tokenizes input on its
+// own, behaves exactly like Stream, which relies on json.Decoder: the same
+// values are passed to fn, and the same output or error is produced.
+func TestMessageMatchesStream(t *testing.T) {
+	for _, input := range testCorpus() {
+		var calls1, calls2 []string
+		record := func(calls *[]string) sanitize.Func {
+			return func(f sanitize.Field) (string, bool) {
+				*calls = append(*calls, fmt.Sprintf("%v %q %q %v", f.Kind, f.Key, f.Value, f.Path))
+				return f.Value + "!", true
+			}
+		}
+		dst, err1 := sanitize.MessageFunc(nil, []byte(input), record(&calls1))
+		buf := new(bytes.Buffer)
+		err2 := sanitize.StreamFunc(buf, bytes.NewReader([]byte(input)), record(&calls2))
+		if fmt.Sprint(err1) != fmt.Sprint(err2) {
+			t.Fatalf("%q: Message error: %v, Stream error: %v", input, err1, err2)
+		}
+		if err1 != nil {
+			continue
+		}
+		if !reflect.DeepEqual(calls1, calls2) {
+			t.Fatalf("%q: fn called differently:\nMessage: %q\nStream:  %q", input, calls1, calls2)
+		}
+		if !bytes.Equal(dst, buf.Bytes()) {
+			t.Fatalf("%q: outputs differ:\nMessage: %q\nStream:  %q", input, dst, buf)
+		}
+	}
+}
+
+// TestMessageMaxNesting checks that Message rejects payloads nested deeper
+// than json.Decoder allows with the same error Stream reports.
+func TestMessageMaxNesting(t *testing.T) {
+	for _, depth := range []int{10000, 10001, 100000} {
+		input := []byte(strings.Repeat("[", depth) + strings.Repeat("]", depth))
+		_, err1 := sanitize.Message(nil, input, fn)
+		err2 := sanitize.Stream(ioutil.Discard, bytes.NewReader(input), fn)
+		if fmt.Sprint(err1) != fmt.Sprint(err2) {
+			t.Fatalf("depth %d: Message error: %v, Stream error: %v", depth, err1, err2)
+		}
+		if (err1 == nil) != (depth <= 10000) {
+			t.Fatalf("depth %d: unexpected error: %v", depth, err1)
+		}
+	}
+}
+
+// testCorpus returns a corpus of valid and malformed json payloads along with
+// random mutations of them
+func testCorpus() []string {
+	corpus := []string{
+		``, ` `, `{}`, `[]`, `{} []`, `{}{}`, `1 2`, `12`, `01`, `-01`, `1.5.3`, `"a""b"`,
+		`truefalse`, `nulltrue`, `1"a"`, `[]1`, `1[]`, `-`, `-a`, `1.`, `1.e3`, `1e`, `1e+`,
+		`1E-7`, `-0.0e+00`, `1e400`, `tru`, `nul`, `nullx`, `[truex]`, `[true x]`, `[1,]`,
+		`[,1]`, `[1 2]`, `{"a"}`, `{"a":}`, `{"a":1,}`, `{,"a":1}`, `{"a" 1}`, `{"a"::1}`,
+		`{1:1}`, `{"a":1 "b":2}`, `]`, `}`, `[}`, `{]`, `[[[]]]`, `[{"a":[{"b":{}}]}]`,
+		`{"a":"b`, `{"a":"b\`, `"\u00"`, `"\uD800"`, `"\uD800A"`, `"\uDC00\uD800"`,
+		`"😀"`, `"😀x"`, `"\uD83D😀"`, `"\x"`, `"\'"`, `"\/"`,
+		`"\b\f\n\r\t\"\\"`, "\"\t\"", "\"\x00\"", "\"\x7f\"", "\"\xff\"", "\"a\xed\xa0\x80b\"",
+		"\"\xe2\x80\"", "\"\xf0\x9f\x98\x80\"", `"<&>"`, " \t\r\n{ \"a\" : [ 1 , true ] } \n",
+		"\v1", "\f1", `{"a":{"b":[1,{"c":null}],"d":"e"},"f":[[],[{}]]}`, `[1,[2,[3]],4]`,
+		`{"a":1}}`, `[1]]`, `"abc`, `[`, `{`, `{"a"`, `{"a":`, `{"a":1`, `{"a":1,`, `[1,`,
+	}
+	r := rand.New(rand.NewSource(1))
+	mutated := make([]string, 0, 5000)
+	for i := 0; i < cap(mutated); i++ {
+		src := []byte(corpus[r.Intn(len(corpus))])
+		for n := r.Intn(4); n >= 0; n-- {
+			const alphabet = "{}[]:,\"\\ u0123456789abcdefABCDEF-+.eEtrunlsx\x00\x7f\xff"
+			c := alphabet[r.Intn(len(alphabet))]
+			switch i := r.Intn(len(src) + 1); r.Intn(3) {
+			case 0:
+				src = append(src[:i], append([]byte{c}, src[i:]...)...)
+			case 1:
+				if i < len(src) {
+					src = append(src[:i], src[i+1:]...)
+				}
+			case 2:
+				if i < len(src) {
+					src[i] = c
+				}
+			}
+		}
+		mutated = append(mutated, string(src))
+	}
+	return append(corpus, mutated...)
+}
@@ -0,0 +1,203 @@
+package sanitize
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// FromSchema returns PathFunc that substitutes with mask string values at
+// locations marked with "x-sensitive": true in JSON Schema document schema.
+// If a property holding an object or an array is marked, all string values
+// nested under it are masked.
+//
+// Schema is walked through "properties" of objects and "items" of arrays,
+// including the draft-04 list form of "items" and "prefixItems". Subschemas
+// of "allOf", "anyOf" and "oneOf" apply to the same location, and so do
+// same-document references like {"$ref": "#/definitions/user"}, which may be
+// recursive. References to other documents are reported as errors. Other
+// keywords, like "additionalProperties", are ignored.
+func FromSchema(schema []byte, mask string) (PathFunc, error) {
+	var doc interface{}
+	if err := json.Unmarshal(schema, &doc); err != nil {
+		return nil, fmt.Errorf("sanitize: parsing schema: %w", err)
+	}
+	c := schemaCompiler{doc: doc, refs: make(map[string]*schemaNode)}
+	root, err := c.compile(doc)
+	if err != nil {
+		return nil, err
+	}
+	return func(path []PathElem, _ string) (string, bool) {
+		if root.match(path) {
+			return mask, true
+		}
+		return "", false
+	}, nil
+}
+
+// schemaNode describes values at some location of the payload
+type schemaNode struct {
+	sensitive bool
+	props     map[string]*schemaNode
+	items     []*schemaNode // schemas of all array elements
+	tuple     []*schemaNode // schemas of array elements by index
+	alts      []*schemaNode // schemas applying to the same location
+}
+
+// match reports whether path leads to or through a sensitive location
+func (n *schemaNode) match(path []PathElem) bool {
+	cur := expandSchema(nil, n)
+	var next []*schemaNode
+	for _, p := range path {
+		next = next[:0]
+		for _, n := range cur {
+			if n.sensitive {
+				return true
+			}
+			if p.Index < 0 {
+				if c := n.props[p.Key]; c != nil {
+					next = expandSchema(next, c)
+				}
+				continue
+			}
+			for _, c := range n.items {
+				next = expandSchema(next, c)
+			}
+			if p.Index < len(n.tuple) {
+				next = expandSchema(next, n.tuple[p.Index])
+			}
+		}
+		if len(next) == 0 {
+			return false
+		}
+		cur, next = next, cur
+	}
+	for _, n := range cur {
+		if n.sensitive {
+			return true
+		}
+	}
+	return false
+}
+
+// expandSchema adds c and all its alternatives to set, skipping nodes already
+// in set
+func expandSchema(set []*schemaNode, c *schemaNode) []*schemaNode {
+	for _, n := range set {
+		if n == c {
+			return set
+		}
+	}
+	set = append(set, c)
+	for _, a := range c.alts {
+		set = expandSchema(set, a)
+	}
+	return set
+}
+
+type schemaCompiler struct {
+	doc  interface{}
+	refs map[string]*schemaNode // compiled $ref targets
+}
+
+func (c *schemaCompiler) compile(v interface{}) (*schemaNode, error) {
+	n := &schemaNode{}
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return n, nil // boolean schema
+	}
+	n.sensitive = m["x-sensitive"] == true
+	if ref, ok := m["$ref"].(string); ok {
+		a, err := c.ref(ref)
+		if err != nil {
+			return nil, err
+		}
+		n.alts = append(n.alts, a)
+	}
+	if props, ok := m["properties"].(map[string]interface{}); ok {
+		n.props = make(map[string]*schemaNode, len(props))
+		for k, p := range props {
+			pn, err := c.compile(p)
+			if err != nil {
+				return nil, err
+			}
+			n.props[k] = pn
+		}
+	}
+	tuple := m["prefixItems"]
+	switch items := m["items"].(type) {
+	case map[string]interface{}, bool:
+		in, err := c.compile(items)
+		if err != nil {
+			return nil, err
+		}
+		n.items = append(n.items, in)
+	case []interface{}:
+		tuple = items
+	}
+	if list, ok := tuple.([]interface{}); ok {
+		for _, s := range list {
+			sn, err := c.compile(s)
+			if err != nil {
+				return nil, err
+			}
+			n.tuple = append(n.tuple, sn)
+		}
+	}
+	for _, kw := range []string{"allOf", "anyOf", "oneOf"} {
+		list, _ := m[kw].([]interface{})
+		for _, s := range list {
+			sn, err := c.compile(s)
+			if err != nil {
+				return nil, err
+			}
+			n.alts = append(n.alts, sn)
+		}
+	}
+	return n, nil
+}
+
+// ref returns compiled schema referenced by same-document reference ref
+func (c *schemaCompiler) ref(ref string) (*schemaNode, error) {
+	if n, ok := c.refs[ref]; ok {
+		return n, nil
+	}
+	if !strings.HasPrefix(ref, "#") {
+		return nil, fmt.Errorf("sanitize: unsupported schema $ref %q", ref)
+	}
+	v := c.doc
+	if ptr := ref[1:]; ptr != "" {
+		if ptr[0] != '/' {
+			return nil, fmt.Errorf("sanitize: unsupported schema $ref %q", ref)
+		}
+		for _, tok := range strings.Split(ptr[1:], "/") {
+			tok = strings.NewReplacer("~1", "/", "~0", "~").Replace(tok)
+			switch x := v.(type) {
+			case map[string]interface{}:
+				v = x[tok]
+			case []interface{}:
+				i, err := strconv.Atoi(tok)
+				if err != nil || i < 0 || i >= len(x) {
+					v = nil
+				} else {
+					v = x[i]
+				}
+			default:
+				v = nil
+			}
+			if v == nil {
+				return nil, fmt.Errorf("sanitize: schema $ref %q not found", ref)
+			}
+		}
+	}
+	// placeholder breaks cycles of recursive references
+	n := &schemaNode{}
+	c.refs[ref] = n
+	cn, err := c.compile(v)
+	if err != nil {
+		return nil, err
+	}
+	*n = *cn
+	return n, nil
+}
@@ -0,0 +1,82 @@
+package sanitize_test
+
+import (
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestFromSchema(t *testing.T) {
+	const schema = `{
+		"type": "object",
+		"properties": {
+			"name": {"type": "string"},
+			"ssn": {"type": "string", "x-sensitive": true},
+			"address": {
+				"type": "object",
+				"properties": {
+					"city": {"type": "string"},
+					"street": {"type": "string", "x-sensitive": true}
+				}
+			},
+			"cards": {"type": "array", "items": {"$ref": "#/definitions/card"}},
+			"secrets": {"type": "object", "x-sensitive": true},
+			"pair": {"type": "array", "items": [{"type": "string"}, {"x-sensitive": true}]},
+			"manager": {"$ref": "#/definitions/person"},
+			"extra": {"allOf": [{"properties": {"a": {"x-sensitive": true}}}, {"properties": {"b": {}}}]}
+		},
+		"definitions": {
+			"card": {"type": "object", "properties": {"pan": {"x-sensitive": true}, "brand": {}}},
+			"person": {
+				"properties": {
+					"email": {"x-sensitive": true},
+					"name": {},
+					"manager": {"$ref": "#/definitions/person"}
+				}
+			}
+		}
+	}`
+	const input = `{"name":"n","ssn":"1","address":{"city":"c","street":"2"},` +
+		`"cards":[{"pan":"3","brand":"b"},{"pan":"4"}],"secrets":{"a":"5","b":["6",{"c":"7"}]},` +
+		`"pair":["p","8"],"manager":{"name":"m","email":"9","manager":{"email":"10","name":"m2"}},` +
+		`"extra":{"a":"11","b":"x"},"unknown":{"ssn":"u"}}`
+	const want = `{"name":"n","ssn":"*","address":{"city":"c","street":"*"},` +
+		`"cards":[{"pan":"*","brand":"b"},{"pan":"*"}],"secrets":{"a":"*","b":["*",{"c":"*"}]},` +
+		`"pair":["p","*"],"manager":{"name":"m","email":"*","manager":{"email":"*","name":"m2"}},` +
+		`"extra":{"a":"*","b":"x"},"unknown":{"ssn":"u"}}`
+	fn, err := sanitize.FromSchema([]byte(schema), "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := sanitize.MessagePath(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestFromSchemaErrors(t *testing.T) {
+	for _, schema := range []string{
+		`{"properties":`,
+		`{"properties":{"a":{"$ref":"other.json#/a"}}}`,
+		`{"properties":{"a":{"$ref":"#/definitions/missing"}}}`,
+	} {
+		if _, err := sanitize.FromSchema([]byte(schema), "*"); err == nil {
+			t.Errorf("%s: no error", schema)
+		}
+	}
+	// self-referencing schema must not loop forever
+	fn, err := sanitize.FromSchema([]byte(`{"$ref":"#","properties":{"a":{"$ref":"#"},"s":{"x-sensitive":true}}}`), "*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst, err := sanitize.MessagePath(nil, []byte(`{"a":{"a":{"s":"x","b":"y"}}}`), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := string(dst), `{"a":{"a":{"s":"*","b":"y"}}}`; got != want {
+		t.Fatalf("got %s, want %s", got, want)
+	}
+}
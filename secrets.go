@@ -0,0 +1,128 @@
+package sanitize
+
+import "strings"
+
+// RedactValues returns FieldFunc that substitutes with mask values exactly
+// equal to any of secrets, like known leaked credentials, regardless of the
+// key they are stored under. Values merely containing a secret are kept.
+func RedactValues(mask string, secrets ...string) FieldFunc {
+	set := make(map[string]struct{}, len(secrets))
+	for _, s := range secrets {
+		set[s] = struct{}{}
+	}
+	return RedactValueSet(mask, set)
+}
+
+// RedactValueSet is a variant of RedactValues taking a set of secrets. The set
+// is used as is and must not be modified while FieldFunc is in use.
+func RedactValueSet(mask string, secrets map[string]struct{}) FieldFunc {
+	return func(_, value string) (string, bool) {
+		if _, ok := secrets[value]; ok {
+			return mask, true
+		}
+		return "", false
+	}
+}
+
+// ScrubSubstrings returns function that replaces every occurrence of each of
+// needles within its argument with replacement, like an API key appearing
+// inside a URL. Use it with ReplaceInValue.
+//
+// Where occurrences of needles overlap, like "abcd" and "cdef" within
+// "abcdef", the whole overlapping span is replaced with a single replacement,
+// so no part of any needle is left. Occurrences that merely touch are
+// replaced separately. Empty needles are ignored. If needles and replacement
+// are valid UTF-8, so is the result for valid UTF-8 argument.
+//
+// Needles are matched with Aho-Corasick automaton, so cost of the scan
+// depends on the length of the argument, not on the number of needles or on
+// how many of them share a prefix, like "sk_" or "ghp_".
+func ScrubSubstrings(replacement string, needles ...string) func(string) string {
+	nodes := scrubAutomaton(needles)
+	return func(s string) string {
+		var spans [][2]int // merged occurrences found so far, in order
+		cur := 0
+		for i := 0; i < len(s); i++ {
+			c := s[i]
+			for cur != 0 && nodes[cur].next[c] == 0 {
+				cur = nodes[cur].fail
+			}
+			cur = nodes[cur].next[c]
+			n := nodes[cur].longest
+			if n == 0 {
+				continue
+			}
+			// longest needle ending at i covers all shorter ones; merge
+			// it with spans it overlaps
+			start, end := i+1-n, i+1
+			for len(spans) > 0 && spans[len(spans)-1][1] > start {
+				if prev := spans[len(spans)-1][0]; prev < start {
+					start = prev
+				}
+				spans = spans[:len(spans)-1]
+			}
+			spans = append(spans, [2]int{start, end})
+		}
+		if spans == nil {
+			return s
+		}
+		var b strings.Builder
+		last := 0 // end of s already copied to b
+		for _, sp := range spans {
+			b.WriteString(s[last:sp[0]])
+			b.WriteString(replacement)
+			last = sp[1]
+		}
+		b.WriteString(s[last:])
+		return b.String()
+	}
+}
+
+// scrubNode is a state of Aho-Corasick automaton built by scrubAutomaton
+type scrubNode struct {
+	next    map[byte]int // trie edges, 0 for none, as root is never a target
+	fail    int          // state of the longest proper suffix that is in trie
+	longest int          // length of the longest needle ending at this state
+}
+
+// scrubAutomaton returns states of Aho-Corasick automaton matching needles,
+// the first one is the root; empty needles are ignored
+func scrubAutomaton(needles []string) []scrubNode {
+	nodes := []scrubNode{{next: make(map[byte]int)}}
+	for _, n := range needles {
+		cur := 0
+		for i := 0; i < len(n); i++ {
+			next, ok := nodes[cur].next[n[i]]
+			if !ok {
+				next = len(nodes)
+				nodes = append(nodes, scrubNode{next: make(map[byte]int)})
+				nodes[cur].next[n[i]] = next
+			}
+			cur = next
+		}
+		if len(n) > nodes[cur].longest {
+			nodes[cur].longest = len(n)
+		}
+	}
+	// breadth-first, so fail states are complete before they are used
+	queue := make([]int, 0, len(nodes))
+	for _, next := range nodes[0].next {
+		queue = append(queue, next)
+	}
+	for len(queue) > 0 {
+		cur := queue[0]
+		queue = queue[1:]
+		for c, next := range nodes[cur].next {
+			f := nodes[cur].fail
+			for f != 0 && nodes[f].next[c] == 0 {
+				f = nodes[f].fail
+			}
+			nodes[next].fail = nodes[f].next[c]
+			if l := nodes[nodes[next].fail].longest; l > nodes[next].longest {
+				nodes[next].longest = l
+			}
+			queue = append(queue, next)
+		}
+	}
+	return nodes
+}
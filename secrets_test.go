@@ -0,0 +1,74 @@
+package sanitize_test
+
+import (
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestRedactValues(t *testing.T) {
+	const input = `{"token":"s3cr3t","url":"https://x/?key=s3cr3t","note":"S3CR3T","other":"hunter2",` +
+		`"nested":{"password":"hunter2"},"list":["s3cr3t"],"n":1}`
+	const want = `{"token":"***","url":"https://x/?key=s3cr3t","note":"S3CR3T","other":"***",` +
+		`"nested":{"password":"***"},"list":["s3cr3t"],"n":1}`
+	for _, fn := range []sanitize.FieldFunc{
+		sanitize.RedactValues("***", "s3cr3t", "hunter2"),
+		sanitize.RedactValueSet("***", map[string]struct{}{"s3cr3t": {}, "hunter2": {}}),
+	} {
+		dst, err := sanitize.Message(nil, []byte(input), fn)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := string(dst); got != want {
+			t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+		}
+	}
+	if _, ok := sanitize.RedactValues("***")("k", ""); ok {
+		t.Fatal("empty set matched empty value")
+	}
+}
+
+func TestScrubSubstrings(t *testing.T) {
+	scrub := sanitize.ScrubSubstrings("***", "abcd", "cdef", "key", "ab", "", "ключ")
+	for _, tc := range []struct{ in, want string }{
+		{"", ""},
+		{"nothing here", "nothing here"},
+		{"key", "***"},
+		{"https://x/?key=1&kk=key", "https://x/?***=1&kk=***"},
+		{"keykey", "******"},
+		{"abcdef", "***"},
+		{"xabcdefx", "x***x"},
+		{"xabcx", "x***cx"},
+		{"abcd ab", "*** ***"},
+		{"ключ: \"ключ\"", "***: \"***\""},
+	} {
+		if got := scrub(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	scrub = sanitize.ScrubSubstrings("***", "sk_live_abc", "sk_", "sk_test_", "bcd", "abcde", "abcx", "bc")
+	for _, tc := range []struct{ in, want string }{
+		{"sk_live_abc!", "***!"},
+		{"sk_live_abcd", "***"},
+		{"sk_live_ab", "***live_ab"},
+		{"key=sk_test_1 sk_", "key=***1 ***"},
+		{"abcdx", "a***x"},
+		{"xabcdey", "x***y"},
+		{"abcy", "a***y"},
+		{"abcabcx", "a******"},
+	} {
+		if got := scrub(tc.in); got != tc.want {
+			t.Errorf("%q: got %q, want %q", tc.in, got, tc.want)
+		}
+	}
+	const input = `{"url":"https://api/?token=t0k\"en&x=1","msg":"t0k\"en\u2028"}`
+	const want = `{"url":"https://api/?token=[REDACTED]\u0026x=1","msg":"[REDACTED]\u2028"}`
+	fn := sanitize.ReplaceInValue(sanitize.ScrubSubstrings("[REDACTED]", `t0k"en`))
+	dst, err := sanitize.Message(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -0,0 +1,138 @@
+package sanitize
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// Segmenter splits multipart payload into consecutive segments, some of which
+// hold json to be sanitized while others are opaque data to be copied as is.
+type Segmenter interface {
+	// Next returns reader of the next segment and reports whether segment
+	// holds json payload. Segment reader is only valid until the next call
+	// to Next. Next returns io.EOF when there are no more segments.
+	Next() (seg io.Reader, isJSON bool, err error)
+}
+
+// StreamSegments sanitizes multipart payload split by s, writing result to w.
+// Each json segment is processed with Stream, all other segments are copied
+// verbatim.
+func StreamSegments(w io.Writer, s Segmenter, fn FieldFunc) error {
+	if fn == nil || s == nil {
+		return errInvalidArguents
+	}
+	for {
+		seg, isJSON, err := s.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if isJSON {
+			err = Stream(w, seg, fn)
+		} else {
+			_, err = io.Copy(w, seg)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// NewMarkerSegmenter returns Segmenter splitting r on each occurrence of
+// marker. Segments between markers alternate between json and opaque data,
+// starting with json: json, data, json, data, and so on. Markers themselves
+// are reported as opaque segments, so they are retained in the output of
+// StreamSegments.
+//
+// Input is processed in a streaming fashion, opaque segments are never fully
+// buffered in memory.
+func NewMarkerSegmenter(r io.Reader, marker []byte) Segmenter {
+	size := 4096
+	if len(marker) > size {
+		size = len(marker)
+	}
+	return &markerSegmenter{
+		br:     bufio.NewReaderSize(r, size),
+		marker: marker,
+	}
+}
+
+type markerSegmenter struct {
+	br     *bufio.Reader
+	marker []byte
+	cur    *markerSegment
+	n      int // number of segments returned, excluding markers
+	eof    bool
+}
+
+func (s *markerSegmenter) Next() (io.Reader, bool, error) {
+	if len(s.marker) == 0 {
+		return nil, false, errEmptyMarker
+	}
+	if s.cur != nil {
+		// drain unread remains of the previous segment
+		if _, err := io.Copy(ioutil.Discard, s.cur); err != nil {
+			return nil, false, err
+		}
+		marked := s.cur.marked
+		s.eof = s.cur.eof
+		s.cur = nil
+		if marked {
+			return bytes.NewReader(s.marker), false, nil
+		}
+	}
+	if s.eof {
+		return nil, false, io.EOF
+	}
+	s.cur = &markerSegment{br: s.br, marker: s.marker}
+	isJSON := s.n%2 == 0
+	s.n++
+	return s.cur, isJSON, nil
+}
+
+// markerSegment is a reader returning data up to the next marker
+type markerSegment struct {
+	br     *bufio.Reader
+	marker []byte
+	marked bool // segment ended with marker, marker is consumed
+	eof    bool // segment ended with end of input
+}
+
+func (s *markerSegment) Read(p []byte) (int, error) {
+	if s.marked || s.eof {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+	buf, err := s.br.Peek(s.br.Size())
+	if err != nil && err != io.EOF {
+		return 0, err
+	}
+	if i := bytes.Index(buf, s.marker); i >= 0 {
+		if i == 0 {
+			s.br.Discard(len(s.marker))
+			s.marked = true
+			return 0, io.EOF
+		}
+		buf = buf[:i]
+	} else if err == nil {
+		// marker may start in the tail of the buffer, keep it for the
+		// next read
+		buf = buf[:len(buf)-len(s.marker)+1]
+	}
+	if len(buf) == 0 {
+		s.eof = true
+		return 0, io.EOF
+	}
+	n := copy(p, buf)
+	s.br.Discard(n)
+	return n, nil
+}
+
+var errEmptyMarker = errors.New("sanitize: empty segment marker")
@@ -0,0 +1,24 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamSegments(t *testing.T) {
+	const marker = "\n--SEP--\n"
+	blob := strings.Repeat("\x00\x01binary {\"Msg\":\"Hi\"}", 500)
+	input := input + marker + blob + marker + `{"Msg":"Bye","Num":2}`
+	want := want + marker + blob + marker + `{"Msg":"********","Num":2}`
+	buf := new(bytes.Buffer)
+	s := sanitize.NewMarkerSegmenter(strings.NewReader(input), []byte(marker))
+	if err := sanitize.StreamSegments(buf, s, fn); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("got:\n%q\nwant:\n%q", got, want)
+	}
+}
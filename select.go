@@ -0,0 +1,118 @@
+package sanitize
+
+import (
+	"bytes"
+	"io"
+)
+
+// Selector is called on each top-level json value of the payload processed
+// by StreamSelect or MessageSelect to choose FieldFunc sanitizing this value.
+// For objects fields holds their members with scalar values: strings
+// unquoted, numbers, booleans and null as they are written in the payload,
+// like "42" or "true". Members holding objects and arrays are not included.
+// For other top-level values fields is nil. If Selector returns nil, value is
+// written as is.
+type Selector func(fields map[string]string) FieldFunc
+
+// StreamSelect is a variant of Stream for payloads multiplexing different
+// kinds of messages, like a stream of events distinguished by their "type"
+// member, where each kind needs its own rules. sel is called on each
+// top-level value to choose FieldFunc used for that value.
+//
+// As the member sel depends on may come last, each top-level value is read
+// into memory in full before it is sanitized, so this is not suited for
+// payloads holding a single huge value. Consecutive top-level values are
+// processed one at a time.
+func StreamSelect(w io.Writer, r io.Reader, sel Selector) error {
+	if sel == nil {
+		return errInvalidArguents
+	}
+	return stream(w, r, &state{selectFn: sel})
+}
+
+// MessageSelect is a variant of Message that calls sel on each top-level
+// value to choose FieldFunc used for that value, see StreamSelect.
+func MessageSelect(dst, src []byte, sel Selector) ([]byte, error) {
+	if sel == nil {
+		return nil, errInvalidArguents
+	}
+	return message(dst, src, &state{selectFn: sel})
+}
+
+// runSelect processes payload calling s.selectFn on each top-level value
+func (s *state) runSelect() error {
+	var buf bytes.Buffer
+	var out []byte
+	for {
+		t, err := s.tok.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return s.syntaxError(err)
+		}
+		if s.ntop > 0 {
+			s.w.WriteByte('\n')
+		}
+		var fields map[string]string
+		if t.delim == '{' {
+			fields = make(map[string]string)
+		}
+		buf.Reset()
+		if err := s.copyValue(&buf, t, fields); err != nil {
+			return err
+		}
+		if fn := s.selectFn(fields); fn != nil {
+			if out, err = Message(out, buf.Bytes(), fn); err != nil {
+				return err
+			}
+			s.w.Write(out)
+		} else {
+			s.w.Write(buf.Bytes())
+		}
+		s.ntop++
+	}
+}
+
+// copyValue writes value starting with token t to w in compact form. If
+// fields is non-nil, value is an object, and its members holding scalars are
+// stored in fields.
+func (s *state) copyValue(w writer, t token, fields map[string]string) error {
+	return s.walkValue(w, t, 0, func(w writer, depth int) error {
+		return s.copyObject(w, depth, fields)
+	})
+}
+
+// copyObject writes members of the object which opening brace is already
+// consumed to w, storing members holding scalars in fields if it is non-nil
+func (s *state) copyObject(w writer, depth int, fields map[string]string) error {
+	nested := func(w writer, depth int) error { return s.copyObject(w, depth, nil) }
+	w.WriteByte('{')
+	for n := 0; ; n++ {
+		t, err := s.nextObjectToken()
+		if err != nil {
+			return err
+		}
+		if t.delim == '}' {
+			break
+		}
+		if n > 0 {
+			w.WriteByte(',')
+		}
+		key := t.v
+		w.WriteByte('"')
+		writeEscapedString(w, key, true)
+		w.WriteString(`":`)
+		if t, err = s.nextObjectToken(); err != nil {
+			return err
+		}
+		if fields != nil && t.delim == 0 {
+			fields[key] = t.v
+		}
+		if err := s.walkValue(w, t, depth, nested); err != nil {
+			return err
+		}
+	}
+	w.WriteByte('}')
+	return nil
+}
@@ -0,0 +1,80 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamSelect(t *testing.T) {
+	const input = `{"type":"login","user":"bob","password":"x","card":"1"}
+{"user":"amy","card":"4111","meta":{"type":"login","password":"y"},"n":1,"type":"payment"}
+{"type":"other","password":"z"}
+"password"
+[{"type":"login","password":"w"}]`
+	const want = `{"type":"login","user":"bob","password":"***","card":"1"}
+{"user":"amy","card":"####","meta":{"type":"login","password":"y"},"n":1,"type":"payment"}
+{"type":"other","password":"z"}
+"password"
+[{"type":"login","password":"w"}]`
+	var seen []map[string]string
+	sel := func(fields map[string]string) sanitize.FieldFunc {
+		seen = append(seen, fields)
+		switch fields["type"] {
+		case "login":
+			return sanitize.FromMap(map[string]string{"password": "***"})
+		case "payment":
+			return sanitize.FromMap(map[string]string{"card": "####"})
+		}
+		return nil
+	}
+	dst, err := sanitize.MessageSelect(nil, []byte(input), sel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	wantSeen := []map[string]string{
+		{"type": "login", "user": "bob", "password": "x", "card": "1"},
+		{"user": "amy", "card": "4111", "n": "1", "type": "payment"},
+		{"type": "other", "password": "z"},
+		nil,
+		nil,
+	}
+	if !reflect.DeepEqual(seen, wantSeen) {
+		t.Fatalf("got fields:\n%v\nwant:\n%v", seen, wantSeen)
+	}
+	buf := new(bytes.Buffer)
+	if err := sanitize.StreamSelect(buf, strings.NewReader(input), sel); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Fatalf("StreamSelect got:\n%s\nwant:\n%s", got, want)
+	}
+	var serr *sanitize.SyntaxError
+	if _, err := sanitize.MessageSelect(nil, []byte(`{"type":"login","password":`), sel); !errors.As(err, &serr) {
+		t.Fatalf("got %v, want *SyntaxError", err)
+	}
+}
+
+func TestStreamSelectDeepNesting(t *testing.T) {
+	sel := func(map[string]string) sanitize.FieldFunc { return nil }
+	for _, depth := range []int{10000, 10001, 1000000} {
+		input := strings.Repeat(`{"a":[`, depth/2) + strings.Repeat("[", depth%2) +
+			strings.Repeat("]", depth%2) + strings.Repeat(`]}`, depth/2)
+		_, err := sanitize.MessageSelect(nil, []byte(input), sel)
+		if (err == nil) != (depth <= 10000) {
+			t.Fatalf("MessageSelect, depth %d: unexpected error: %v", depth, err)
+		}
+		err = sanitize.StreamSelect(ioutil.Discard, strings.NewReader(input), sel)
+		if (err == nil) != (depth <= 10000) {
+			t.Fatalf("StreamSelect, depth %d: unexpected error: %v", depth, err)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package sanitize
+
+// MessageStats is a variant of Message that also reports how many values were
+// substituted for each key.
+func MessageStats(dst, src []byte, fn FieldFunc) ([]byte, map[string]int, error) {
+	if fn == nil {
+		return nil, nil, errInvalidArguents
+	}
+	stats := make(map[string]int)
+	count := func(key, value string) (string, bool) {
+		val, ok := fn(key, value)
+		if ok {
+			stats[key]++
+		}
+		return val, ok
+	}
+	out, err := Message(dst, src, count)
+	if err != nil {
+		return nil, nil, err
+	}
+	return out, stats, nil
+}
+
+// MessageResult holds sanitized payload along with details of its processing,
+// see MessageX.
+type MessageResult struct {
+	Output   []byte // sanitized payload, nil if input is not valid
+	Replaced int    // number of values substituted
+	MaxDepth int    // deepest nesting of objects and arrays, 0 for scalars
+	Valid    bool   // whether the whole input is valid json
+}
+
+// MessageX is a variant of Message that also reports details of processing.
+// If src is not a valid json, MessageX returns *SyntaxError, and Replaced and
+// MaxDepth of the result only describe input processed up to the malformed
+// part.
+func MessageX(dst, src []byte, fn FieldFunc) (MessageResult, error) {
+	var res MessageResult
+	if fn == nil {
+		return res, errInvalidArguents
+	}
+	z := getSanitizer()
+	defer putSanitizer(z)
+	z.s.fn = func(f Field) (string, bool) {
+		val, ok := fn.field(f)
+		if ok {
+			res.Replaced++
+		}
+		return val, ok
+	}
+	out, err := message(dst, src, &z.s)
+	res.MaxDepth = z.s.maxDepth
+	if err != nil {
+		return res, err
+	}
+	res.Output, res.Valid = out, true
+	return res, nil
+}
@@ -0,0 +1,41 @@
+package sanitize_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestMessageStats(t *testing.T) {
+	const input = `{"Msg":"Hi","list":[{"Msg":"a","c":"b"},{"Msg":"c","d":"e"},{"c":1}]}`
+	_, stats, err := sanitize.MessageStats(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]int{"Msg": 3, "c": 1}; !reflect.DeepEqual(stats, want) {
+		t.Fatalf("got %v, want %v", stats, want)
+	}
+}
+
+func TestMessageX(t *testing.T) {
+	res, err := sanitize.MessageX(nil, []byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := sanitize.MessageResult{Output: []byte(want), Replaced: 2, MaxDepth: 2, Valid: true}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("got %+v, want %+v", res, want)
+	}
+	res, err = sanitize.MessageX(nil, []byte(`{"Msg":"x","a":[[{"c":"y"}]],"b":`), fn)
+	if err == nil {
+		t.Fatal("no error on truncated input")
+	}
+	want = sanitize.MessageResult{Replaced: 2, MaxDepth: 4}
+	if !reflect.DeepEqual(res, want) {
+		t.Fatalf("got %+v, want %+v", res, want)
+	}
+	if res, err = sanitize.MessageX(nil, []byte(`"secret"`), fn); err != nil || res.MaxDepth != 0 || !res.Valid {
+		t.Fatalf("got %+v, %v", res, err)
+	}
+}
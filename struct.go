@@ -0,0 +1,86 @@
+package sanitize
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// FromStruct returns FieldFunc that substitutes with mask values of
+// attributes named after fields of v tagged as sensitive, like
+//
+//	type User struct {
+//		Login    string `json:"login"`
+//		Password string `json:"password,omitempty" sensitive:"true"`
+//	}
+//
+// Field names are taken from json tags the same way encoding/json does: name
+// before the first comma of the tag, or the Go field name if the tag has no
+// name; fields tagged with json:"-" and unexported fields are ignored. Fields
+// of embedded structs are treated as fields of the outer struct unless the
+// embedded struct has a json name. Types of struct fields, including pointers,
+// slices, arrays and maps of structs, are inspected too, so rules of nested
+// types are collected as well. Value of the sensitive tag is parsed with
+// strconv.ParseBool.
+//
+// Matching is done by name only, regardless of where the attribute is found
+// in the payload. FromStruct panics if v is not a struct or a pointer to
+// a struct.
+func FromStruct(v interface{}, mask string) FieldFunc {
+	t := reflect.TypeOf(v)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("sanitize: FromStruct called with %T, not a struct", v))
+	}
+	m := make(map[string]string)
+	collectSensitive(t, m, make(map[reflect.Type]bool), mask)
+	return FromMap(m)
+}
+
+// collectSensitive adds json names of fields of struct type t tagged as
+// sensitive to m
+func collectSensitive(t reflect.Type, m map[string]string, seen map[reflect.Type]bool, mask string) {
+	if seen[t] {
+		return
+	}
+	seen[t] = true
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := tag
+		if i := strings.IndexByte(tag, ','); i >= 0 {
+			name = tag[:i]
+		}
+		ft := f.Type
+		for {
+			switch ft.Kind() {
+			case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+				ft = ft.Elem()
+				continue
+			}
+			break
+		}
+		if f.Anonymous && name == "" && ft.Kind() == reflect.Struct {
+			collectSensitive(ft, m, seen, mask)
+			continue
+		}
+		if f.PkgPath != "" {
+			continue // unexported
+		}
+		if name == "" {
+			name = f.Name
+		}
+		if ok, _ := strconv.ParseBool(f.Tag.Get("sensitive")); ok {
+			m[name] = mask
+		}
+		if ft.Kind() == reflect.Struct {
+			collectSensitive(ft, m, seen, mask)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package sanitize_test
+
+import (
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+type structCard struct {
+	Number string `json:"number" sensitive:"true"`
+	Holder string `json:"holder"`
+}
+
+type structAudit struct {
+	IP string `json:"ip,omitempty" sensitive:"1"`
+}
+
+type structBase struct {
+	Token string `sensitive:"true"`
+	structAudit
+}
+
+type structUser struct {
+	structBase
+	Login    string                  `json:"login"`
+	Password string                  `json:"password,omitempty" sensitive:"true"`
+	Secret   string                  `json:"-" sensitive:"true"`
+	Opaque   string                  `json:",omitempty" sensitive:"true"`
+	Note     string                  `json:"note" sensitive:"false"`
+	Cards    []*structCard           `json:"cards"`
+	Extra    map[string]structNested `json:"extra"`
+	Named    structAudit             `json:"named" sensitive:"true"`
+	Self     *structUser             `json:"self"`
+	hidden   string                  `sensitive:"true"`
+}
+
+type structNested struct {
+	PIN string `json:"pin" sensitive:"yes"`
+	Key string `json:"key" sensitive:"t"`
+}
+
+func TestFromStruct(t *testing.T) {
+	const input = `{"Token":"1","ip":"2","login":"3","password":"4","Secret":"5","-":"6","Opaque":"7",` +
+		`"note":"8","cards":[{"number":"9","holder":"10"}],"extra":{"x":{"pin":"11","key":"12"}},` +
+		`"named":"13","self":{"password":"14"},"hidden":"15","Password":"16"}`
+	const want = `{"Token":"*","ip":"*","login":"3","password":"*","Secret":"5","-":"6","Opaque":"*",` +
+		`"note":"8","cards":[{"number":"*","holder":"10"}],"extra":{"x":{"pin":"11","key":"*"}},` +
+		`"named":"*","self":{"password":"*"},"hidden":"15","Password":"16"}`
+	dst, err := sanitize.Message(nil, []byte(input), sanitize.FromStruct(&structUser{}, "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(dst); got != want {
+		t.Fatalf("got:\n%s\nwant:\n%s", got, want)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("FromStruct did not panic on non-struct value")
+		}
+	}()
+	sanitize.FromStruct("x", "*")
+}
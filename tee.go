@@ -0,0 +1,94 @@
+package sanitize
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// StreamTee is a variant of Stream that writes the same sanitized output to
+// each of ws, sanitizing input only once.
+//
+// Once some writer fails, no more output is written to it, but the rest of
+// writers still get the complete output. If all of them fail, processing
+// stops within a few hundred tokens past the failure, without consuming the
+// rest of input. Writer failures are then reported as *TeeError. If input itself is
+// not a valid json, or reading it fails, StreamTee returns that error
+// instead, the same way Stream does. To stop on the first writer failure,
+// use Stream with io.MultiWriter.
+func StreamTee(fn FieldFunc, r io.Reader, ws ...io.Writer) error {
+	if fn == nil || len(ws) == 0 {
+		return errInvalidArguents
+	}
+	// output is buffered, so writers only fail once the buffer is flushed;
+	// cancel the context checked between tokens to stop processing then
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	tw := &teeWriter{ws: ws, errs: make([]error, len(ws)), cancel: cancel}
+	s := newState(fn.field, nil)
+	s.ctx = ctx
+	if err := stream(tw, r, s); err != nil && err != errAllWritersFailed && err != context.Canceled {
+		return err
+	}
+	if tw.failed > 0 {
+		return &TeeError{Errs: tw.errs}
+	}
+	return nil
+}
+
+// TeeError is returned by StreamTee when some of its writers fail.
+type TeeError struct {
+	// Errs holds an error for each writer passed to StreamTee in the same
+	// order, nil for writers that got the complete output.
+	Errs []error
+}
+
+func (e *TeeError) Error() string {
+	var b strings.Builder
+	b.WriteString("sanitize: tee writers failed:")
+	for i, err := range e.Errs {
+		if err == nil {
+			continue
+		}
+		b.WriteString(" #")
+		b.WriteString(strconv.Itoa(i))
+		b.WriteString(": ")
+		b.WriteString(err.Error())
+		b.WriteByte(';')
+	}
+	return strings.TrimSuffix(b.String(), ";")
+}
+
+// errAllWritersFailed stops processing once no writer of StreamTee is left
+var errAllWritersFailed = errors.New("sanitize: all writers failed")
+
+// teeWriter writes to each of ws until it fails
+type teeWriter struct {
+	ws     []io.Writer
+	errs   []error // error of each writer
+	failed int     // number of failed writers
+	cancel func()  // called once all writers fail
+}
+
+func (t *teeWriter) Write(p []byte) (int, error) {
+	for i, w := range t.ws {
+		if t.errs[i] != nil {
+			continue
+		}
+		n, err := w.Write(p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+		if err != nil {
+			t.errs[i] = err
+			t.failed++
+		}
+	}
+	if t.failed == len(t.ws) {
+		t.cancel()
+		return 0, errAllWritersFailed
+	}
+	return len(p), nil
+}
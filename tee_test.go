@@ -0,0 +1,62 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestStreamTee(t *testing.T) {
+	var a, b bytes.Buffer
+	if err := sanitize.StreamTee(fn, strings.NewReader(input), &a, &b); err != nil {
+		t.Fatal(err)
+	}
+	if a.String() != want || b.String() != want {
+		t.Fatalf("got:\n%s\n%s\nwant:\n%s", a.String(), b.String(), want)
+	}
+}
+
+func TestStreamTeeErrors(t *testing.T) {
+	errBroken := errors.New("broken")
+	var a, b bytes.Buffer
+	err := sanitize.StreamTee(fn, strings.NewReader(input), &a, failingWriter{errBroken}, &b)
+	var terr *sanitize.TeeError
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v, want *TeeError", err)
+	}
+	if len(terr.Errs) != 3 || terr.Errs[0] != nil || terr.Errs[1] != errBroken || terr.Errs[2] != nil {
+		t.Fatalf("got errors %v", terr.Errs)
+	}
+	if a.String() != want || b.String() != want {
+		t.Fatalf("got:\n%s\n%s\nwant:\n%s", a.String(), b.String(), want)
+	}
+	err = sanitize.StreamTee(fn, strings.NewReader(input), failingWriter{errBroken}, failingWriter{io.ErrClosedPipe})
+	if !errors.As(err, &terr) || terr.Errs[0] != errBroken || terr.Errs[1] != io.ErrClosedPipe {
+		t.Fatalf("got %v, want *TeeError with both writers failed", err)
+	}
+	var serr *sanitize.SyntaxError
+	if err := sanitize.StreamTee(fn, strings.NewReader(`{"a":`), &a); !errors.As(err, &serr) {
+		t.Fatalf("got %v, want *SyntaxError", err)
+	}
+}
+
+func TestStreamTeeStopsOnFailure(t *testing.T) {
+	input := "[" + strings.Repeat(`{"a":"b","n":1},`, 100000) + "1]"
+	r := &countingReader{r: strings.NewReader(input)}
+	err := sanitize.StreamTee(fn, r, failingWriter{io.ErrClosedPipe}, failingWriter{io.ErrClosedPipe})
+	var terr *sanitize.TeeError
+	if !errors.As(err, &terr) {
+		t.Fatalf("got %v, want *TeeError", err)
+	}
+	if r.n > len(input)/10 {
+		t.Fatalf("%d bytes of %d read after all writers failed", r.n, len(input))
+	}
+}
+
+type failingWriter struct{ err error }
+
+func (w failingWriter) Write([]byte) (int, error) { return 0, w.err }
@@ -0,0 +1,77 @@
+package sanitize
+
+import (
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// utf8Reader reads from r failing with ErrInvalidUTF8 once input is not valid
+// UTF-8. Data up to the invalid part is returned before the error.
+type utf8Reader struct {
+	r   io.Reader
+	buf []byte
+	// buf[i:j] is validated data not returned yet, buf[j:k] is a possibly
+	// incomplete character to be completed by the next read
+	i, j, k int
+	off     int64 // number of bytes returned
+	err     error
+}
+
+func (u *utf8Reader) Read(p []byte) (int, error) {
+	for u.i == u.j {
+		if u.err != nil {
+			return 0, u.err
+		}
+		if u.buf == nil {
+			u.buf = make([]byte, 4096)
+		}
+		u.k = copy(u.buf, u.buf[u.j:u.k])
+		u.i, u.j = 0, 0
+		n, err := u.r.Read(u.buf[u.k:])
+		u.k += n
+		end, bad := validPrefix(u.buf[:u.k], err != nil)
+		u.j = end
+		switch {
+		case bad:
+			u.err = fmt.Errorf("%w at offset %d", ErrInvalidUTF8, u.off+int64(end))
+		case err != nil:
+			u.err = err
+		}
+	}
+	n := copy(p, u.buf[u.i:u.j])
+	u.i += n
+	u.off += int64(n)
+	return n, nil
+}
+
+// invalidUTF8 returns offset of the first invalid UTF-8 sequence in b, or -1
+// if b is valid UTF-8
+func invalidUTF8(b []byte) int64 {
+	if end, bad := validPrefix(b, true); bad {
+		return int64(end)
+	}
+	return -1
+}
+
+// validPrefix returns length of the longest prefix of b holding complete valid
+// UTF-8 characters. It reports whether b has invalid sequence right after
+// that prefix; incomplete character at the end of b is only treated as
+// invalid if atEOF is true.
+func validPrefix(b []byte, atEOF bool) (end int, bad bool) {
+	for end < len(b) {
+		if b[end] < utf8.RuneSelf {
+			end++
+			continue
+		}
+		if !utf8.FullRune(b[end:]) {
+			return end, atEOF
+		}
+		r, size := utf8.DecodeRune(b[end:])
+		if r == utf8.RuneError && size == 1 {
+			return end, true
+		}
+		end += size
+	}
+	return end, false
+}
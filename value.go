@@ -0,0 +1,99 @@
+package sanitize
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Value decodes json payload from src into a Go value the same way
+// json.Unmarshal does into an empty interface, substituting string values
+// with fn the same way Message does. Numbers are decoded as json.Number, so
+// no precision is lost.
+//
+// Payload is decoded directly, without producing sanitized json first. src
+// must hold exactly one json value, otherwise Value returns ErrTrailingData.
+func Value(src []byte, fn FieldFunc) (interface{}, error) {
+	if fn == nil {
+		return nil, errInvalidArguents
+	}
+	z := getSanitizer()
+	defer putSanitizer(z)
+	s := &z.s
+	s.setInput(src)
+	t, err := s.nextObjectToken()
+	if err != nil {
+		return nil, err
+	}
+	v, err := s.decodeValue(t, "", true, 0, fn)
+	if err != nil {
+		return nil, err
+	}
+	switch _, err := s.tok.next(); {
+	case err == io.EOF:
+		return v, nil
+	case err == nil:
+		return nil, ErrTrailingData
+	default:
+		return nil, s.syntaxError(err)
+	}
+}
+
+// decodeValue decodes value starting with token t, stored under key and
+// nested in depth objects and arrays; fn is only called on strings that are
+// object members or top-level values
+func (s *state) decodeValue(t token, key string, member bool, depth int, fn FieldFunc) (interface{}, error) {
+	if t.delim != 0 && depth >= maxNestingDepth {
+		return nil, fmt.Errorf("%w: %d", ErrMaxDepthExceeded, maxNestingDepth)
+	}
+	switch t.delim {
+	case '{':
+		obj := make(map[string]interface{})
+		for {
+			t, err := s.nextObjectToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.delim == '}' {
+				return obj, nil
+			}
+			k := t.v
+			if t, err = s.nextObjectToken(); err != nil {
+				return nil, err
+			}
+			if obj[k], err = s.decodeValue(t, k, true, depth+1, fn); err != nil {
+				return nil, err
+			}
+		}
+	case '[':
+		arr := []interface{}{}
+		for {
+			t, err := s.nextObjectToken()
+			if err != nil {
+				return nil, err
+			}
+			if t.delim == ']' {
+				return arr, nil
+			}
+			v, err := s.decodeValue(t, "", false, depth+1, fn)
+			if err != nil {
+				return nil, err
+			}
+			arr = append(arr, v)
+		}
+	}
+	switch t.kind {
+	case String:
+		if member {
+			if val, ok := fn(key, t.v); ok {
+				return val, nil
+			}
+		}
+		return t.v, nil
+	case Number:
+		return json.Number(t.v), nil
+	case Bool:
+		return t.v == "true", nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,64 @@
+package sanitize_test
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestValue(t *testing.T) {
+	got, err := sanitize.Value([]byte(input), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// compare with the result of sanitizing to bytes and decoding them
+	var wantValue interface{}
+	dec := json.NewDecoder(strings.NewReader(want))
+	dec.UseNumber()
+	if err := dec.Decode(&wantValue); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, wantValue) {
+		t.Fatalf("got:\n%#v\nwant:\n%#v", got, wantValue)
+	}
+	const big = `{"n":12345678901234567890.5,"a":[{"Msg":"x"},"Msg"],"e":[],"o":{}}`
+	got, err = sanitize.Value([]byte(big), fn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantBig := map[string]interface{}{
+		"n": json.Number("12345678901234567890.5"),
+		"a": []interface{}{map[string]interface{}{"Msg": sanitize.Mask}, "Msg"},
+		"e": []interface{}{},
+		"o": map[string]interface{}{},
+	}
+	if !reflect.DeepEqual(got, wantBig) {
+		t.Fatalf("got:\n%#v\nwant:\n%#v", got, wantBig)
+	}
+}
+
+func TestValueErrors(t *testing.T) {
+	for _, input := range []string{``, ` `, `{"a":`, `[1,]`, `{"a" 1}`} {
+		var serr *sanitize.SyntaxError
+		if _, err := sanitize.Value([]byte(input), fn); !errors.As(err, &serr) {
+			t.Errorf("%q: got %v, want *SyntaxError", input, err)
+		}
+	}
+	if _, err := sanitize.Value([]byte(`{} {}`), fn); err != sanitize.ErrTrailingData {
+		t.Errorf("got %v, want ErrTrailingData", err)
+	}
+}
+
+func TestValueDeepNesting(t *testing.T) {
+	for _, depth := range []int{10000, 10001, 5000000} {
+		input := strings.Repeat("[", depth) + strings.Repeat("]", depth)
+		_, err := sanitize.Value([]byte(input), fn)
+		if (err == nil) != (depth <= 10000) {
+			t.Fatalf("depth %d: unexpected error: %v", depth, err)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package sanitize
+
+import "io"
+
+// NewWriter returns io.WriteCloser sanitizing json payload written to it,
+// and writing the result to w, the same way Stream does. Payload may be
+// written in chunks of any size, split at arbitrary positions.
+//
+// Close must be called once whole payload is written, it waits until the
+// output is written to w, and returns any error of processing, like that of
+// malformed input. Once processing fails, Write returns the same error.
+//
+// Processing runs in a separate goroutine, which only exits on Close.
+func NewWriter(w io.Writer, fn FieldFunc) io.WriteCloser {
+	pr, pw := io.Pipe()
+	sw := &writeCloser{pw: pw, done: make(chan struct{})}
+	go func() {
+		defer close(sw.done)
+		err := errInvalidArguents
+		if fn != nil {
+			err = Stream(w, pr, fn)
+		}
+		// Stream only returns early on error, make pending and
+		// further writes fail with it
+		sw.err = err
+		pr.CloseWithError(err)
+	}()
+	return sw
+}
+
+type writeCloser struct {
+	pw   *io.PipeWriter
+	done chan struct{}
+	err  error // result of processing, valid once done is closed
+}
+
+func (w *writeCloser) Write(p []byte) (int, error) { return w.pw.Write(p) }
+
+func (w *writeCloser) Close() error {
+	w.pw.Close()
+	<-w.done
+	return w.err
+}
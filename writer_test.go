@@ -0,0 +1,70 @@
+package sanitize_test
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/artyom/sanitize"
+)
+
+func TestNewWriter(t *testing.T) {
+	for _, size := range []int{1, 2, 3, 7, len(input)} {
+		buf := new(bytes.Buffer)
+		w := sanitize.NewWriter(buf, fn)
+		for s := input; len(s) > 0; {
+			n := size
+			if n > len(s) {
+				n = len(s)
+			}
+			if _, err := io.WriteString(w, s[:n]); err != nil {
+				t.Fatal(err)
+			}
+			s = s[n:]
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if got := buf.String(); got != want {
+			t.Fatalf("chunk size %d, got:\n%s\nwant:\n%s", size, got, want)
+		}
+	}
+}
+
+func TestNewWriterPendingToken(t *testing.T) {
+	buf := new(bytes.Buffer)
+	w := sanitize.NewWriter(buf, fn)
+	io.WriteString(w, "12")
+	io.WriteString(w, "3")
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "123" {
+		t.Fatalf("got %q, want %q", got, "123")
+	}
+}
+
+func TestNewWriterError(t *testing.T) {
+	w := sanitize.NewWriter(new(bytes.Buffer), fn)
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		_, err = io.WriteString(w, `{"a":1]`)
+	}
+	var serr *sanitize.SyntaxError
+	if !errors.As(err, &serr) {
+		t.Fatalf("Write: unexpected error: %v", err)
+	}
+	if err := w.Close(); !errors.As(err, &serr) {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+
+	// truncated payload is only detected on Close
+	w = sanitize.NewWriter(new(bytes.Buffer), fn)
+	if _, err := io.WriteString(w, `{"a":`); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Close(); !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("Close: unexpected error: %v", err)
+	}
+}